@@ -3,13 +3,18 @@ package artifacts
 import (
 	"archive/tar"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"time"
 )
 
 type release struct {
@@ -17,10 +22,59 @@ type release struct {
 	Org     string
 	Version string
 	Arch    func(string, string) string
+
+	// Repo is the repository of the release if it is not the name of the binary
+	Repo string
+
+	// Raw is set for the releases that publish the binary without an archive
+	Raw bool
+}
+
+// prysmArch is the architecture suffix of the prysm binaries
+func prysmArch(goos, goarch string) string {
+	if goos == "linux" || goos == "darwin" {
+		return goos + "-" + goarch
+	}
+	return ""
+}
+
+// isWSL returns whether the playground runs in the Windows Subsystem for Linux, which
+// runs the linux binaries
+func isWSL() bool {
+	data, err := os.ReadFile("/proc/version")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "microsoft")
 }
 
-func DownloadArtifacts() (map[string]string, error) {
-	var artifacts = []release{
+// DownloadConfig are the settings of the downloads of the release binaries
+type DownloadConfig struct {
+	// Retries is the number of times a failed download is retried, with an exponential
+	// backoff, before trying the next mirror
+	Retries int
+
+	// Mirrors are the base urls tried in order when the download from GitHub fails. The
+	// path of the release (<org>/<repo>/releases/download/<version>/<file>) is appended.
+	Mirrors []string
+
+	// Offline only uses the binaries already downloaded and fails with the missing ones
+	Offline bool
+}
+
+func DefaultDownloadConfig() *DownloadConfig {
+	return &DownloadConfig{
+		Retries: 3,
+	}
+}
+
+// retryBackoff is the wait before the first retry of a download, it doubles on each retry
+var retryBackoff = time.Second
+
+// DownloadArtifacts downloads the release binaries in names if they are not cached already. The
+// default version of each binary can be overridden by name in versions (i.e. "reth": "v1.1.0").
+func DownloadArtifacts(names []string, versions map[string]string, config *DownloadConfig) (map[string]string, error) {
+	var allArtifacts = []release{
 		{
 			Name:    "reth",
 			Org:     "paradigmxyz",
@@ -51,6 +105,35 @@ func DownloadArtifacts() (map[string]string, error) {
 				return ""
 			},
 		},
+		{
+			Name:    "beacon-chain",
+			Repo:    "prysm",
+			Org:     "prysmaticlabs",
+			Version: "v5.1.2",
+			Arch:    prysmArch,
+			Raw:     true,
+		},
+		{
+			Name:    "validator",
+			Repo:    "prysm",
+			Org:     "prysmaticlabs",
+			Version: "v5.1.2",
+			Arch:    prysmArch,
+			Raw:     true,
+		},
+	}
+
+	if config.Retries < 0 {
+		return nil, fmt.Errorf("the number of retries cannot be negative")
+	}
+
+	var artifacts []release
+	for _, name := range names {
+		idx := slices.IndexFunc(allArtifacts, func(r release) bool { return r.Name == name })
+		if idx == -1 {
+			return nil, fmt.Errorf("unknown artifact %s", name)
+		}
+		artifacts = append(artifacts, allArtifacts[idx])
 	}
 
 	homeDir, err := os.UserHomeDir()
@@ -66,16 +149,26 @@ func DownloadArtifacts() (map[string]string, error) {
 		return nil, fmt.Errorf("error creating output directory: %v", err)
 	}
 
+	for i, artifact := range artifacts {
+		if version, ok := versions[artifact.Name]; ok && version != "" {
+			artifacts[i].Version = version
+		}
+	}
+
 	goos := runtime.GOOS
 	goarch := runtime.GOARCH
 
-	fmt.Printf("Architecture detected: %s/%s\n", goos, goarch)
+	slog.Info("Architecture detected", "os", goos, "arch", goarch)
+	if goos == "linux" && isWSL() {
+		slog.Info("Running in WSL, using the linux binaries")
+	}
 
-	// Try to download the release binaries for 'reth' and 'lighthouse'. It works as follows:
+	// Try to download the release binaries. It works as follows:
 	// 1. Check under $HOME/.playground if the binary-<version> exists. If exists, use it.
 	// 2. If the binary does not exists, use the arch and os to download the binary from the release page.
 	// 3. If the architecture is not supported, check if the binary is found in PATH.
 	releases := make(map[string]string)
+	missing := []string{}
 	for _, artifact := range artifacts {
 		outPath := filepath.Join(customHomeDir, artifact.Name+"-"+artifact.Version)
 		_, err := os.Stat(outPath)
@@ -87,41 +180,125 @@ func DownloadArtifacts() (map[string]string, error) {
 			archVersion := artifact.Arch(goos, goarch)
 			if archVersion == "" {
 				// Case 2. The architecture is not supported.
-				fmt.Printf("unsupported OS/Arch: %s/%s\n", goos, goarch)
+				slog.Warn("Unsupported OS/Arch", "os", goos, "arch", goarch, "release", artifact.Name)
 				if _, err := exec.LookPath(artifact.Name); err != nil {
 					return nil, fmt.Errorf("error looking up binary in PATH: %v", err)
 				} else {
 					outPath = artifact.Name
-					fmt.Printf("Using %s from PATH\n", artifact.Name)
+					slog.Info("Using the binary from PATH", "release", artifact.Name)
 				}
+			} else if config.Offline {
+				missing = append(missing, fmt.Sprintf("%s %s (%s)", artifact.Name, artifact.Version, outPath))
+				continue
 			} else {
 				// Case 3. Download the binary from the release page
-				releasesURL := fmt.Sprintf("https://github.com/%s/%s/releases/download/%s/%s-%s-%s.tar.gz", artifact.Org, artifact.Name, artifact.Version, artifact.Name, artifact.Version, archVersion)
-				fmt.Printf("Downloading %s: %s\n", outPath, releasesURL)
+				repo := artifact.Repo
+				if repo == "" {
+					repo = artifact.Name
+				}
+				releasePath := fmt.Sprintf("%s/%s/releases/download/%s/%s-%s-%s", artifact.Org, repo, artifact.Version, artifact.Name, artifact.Version, archVersion)
+				if !artifact.Raw {
+					releasePath += ".tar.gz"
+				}
 
-				if err := downloadArtifact(releasesURL, artifact.Name, outPath); err != nil {
+				if err := downloadWithMirrors(config, releasePath, artifact.Name, outPath, artifact.Raw); err != nil {
 					return nil, fmt.Errorf("error downloading artifact: %v", err)
 				}
 			}
 		} else {
 			// Case 1. Use the binary in $HOME/.playground
-			fmt.Printf("%s already exists, skipping download\n", outPath)
+			slog.Info("Release already downloaded", "path", outPath)
 		}
 
 		releases[artifact.Name] = outPath
 	}
+	if len(missing) != 0 {
+		return nil, fmt.Errorf("offline mode, the releases are not downloaded: %s", strings.Join(missing, ", "))
+	}
 
 	return releases, nil
 }
 
-func downloadArtifact(url string, expectedFile string, outPath string) error {
+// downloadWithMirrors downloads the release from GitHub and then from each mirror, retrying
+// the transient failures of each one
+func downloadWithMirrors(config *DownloadConfig, releasePath string, expectedFile string, outPath string, raw bool) error {
+	bases := append([]string{"https://github.com"}, config.Mirrors...)
+
+	var err error
+	for _, base := range bases {
+		url := strings.TrimSuffix(base, "/") + "/" + releasePath
+		backoff := retryBackoff
+		for attempt := 0; attempt <= config.Retries; attempt++ {
+			if attempt != 0 {
+				slog.Warn("Retrying download", "url", url, "attempt", attempt, "err", err)
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+			slog.Info("Downloading", "path", outPath, "url", url)
+			if err = downloadArtifact(url, expectedFile, outPath, raw); err == nil {
+				return nil
+			}
+			var permanent *permanentError
+			if errors.As(err, &permanent) {
+				break
+			}
+		}
+		slog.Warn("Download failed", "url", url, "err", err)
+	}
+	return err
+}
+
+// permanentError is a failure of a download that is not fixed by retrying it (i.e. the
+// release does not exist)
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// downloadClient is the client of the downloads. Its transport is the default one, which
+// honors the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables for every request.
+var downloadClient = &http.Client{
+	Transport: newDownloadTransport(),
+}
+
+func newDownloadTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	return transport
+}
+
+func downloadArtifact(url string, expectedFile string, outPath string, raw bool) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	// the proxy is resolved again by the transport for each redirect
+	if proxyURL, err := http.ProxyFromEnvironment(req); err == nil && proxyURL != nil {
+		slog.Info("Using proxy", "proxy", proxyURL.Redacted())
+	}
+
 	// Download the file
-	resp, err := http.Get(url)
+	resp, err := downloadClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("error downloading file: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("error downloading file: status %s", resp.Status)
+		if resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			return &permanentError{err}
+		}
+		return err
+	}
+	if raw {
+		return writeBinary(resp.Body, outPath)
+	}
+
 	// Create a gzip reader
 	gzipReader, err := gzip.NewReader(resp.Body)
 	if err != nil {
@@ -147,19 +324,8 @@ func downloadArtifact(url string, expectedFile string, outPath string) error {
 			if header.Name != expectedFile {
 				return fmt.Errorf("unexpected file in archive: %s", header.Name)
 			}
-			outFile, err := os.Create(outPath)
-			if err != nil {
-				return fmt.Errorf("error creating output file: %v", err)
-			}
-			defer outFile.Close()
-
-			if _, err := io.Copy(outFile, tarReader); err != nil {
-				return fmt.Errorf("error writing output file: %v", err)
-			}
-
-			// change permissions
-			if err := os.Chmod(outPath, 0755); err != nil {
-				return fmt.Errorf("error changing permissions: %v", err)
+			if err := writeBinary(tarReader, outPath); err != nil {
+				return err
 			}
 			found = true
 			break // Assuming there's only one file per repo
@@ -171,3 +337,28 @@ func downloadArtifact(url string, expectedFile string, outPath string) error {
 	}
 	return nil
 }
+
+// writeBinary writes the binary to outPath and makes it executable. It is written to a
+// temporary file first so that an interrupted download is not cached as the release.
+func writeBinary(r io.Reader, outPath string) error {
+	tmpPath := outPath + ".tmp"
+	outFile, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %v", err)
+	}
+	defer os.Remove(tmpPath)
+
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		return fmt.Errorf("error writing output file: %v", err)
+	}
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf("error writing output file: %v", err)
+	}
+
+	// change permissions
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		return fmt.Errorf("error changing permissions: %v", err)
+	}
+	return os.Rename(tmpPath, outPath)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var buildFromSourceFlag []string
+
+// sourceBuilds are the releases of --build-from-source
+var sourceBuilds []*sourceBuild
+
+// sourceBuild is a release binary built from a local checkout of its repository
+type sourceBuild struct {
+	name string
+	path string
+}
+
+// sourceBuildCmds are the build commands of each release binary and the path of the
+// binary they create, relative to the checkout
+var sourceBuildCmds = map[string]struct {
+	args []string
+	bin  string
+}{
+	"reth":         {[]string{"cargo", "build", "--release", "--bin", "reth"}, "target/release/reth"},
+	"lighthouse":   {[]string{"cargo", "build", "--release", "--bin", "lighthouse"}, "target/release/lighthouse"},
+	"beacon-chain": {[]string{"go", "build", "-o", "build/beacon-chain", "./cmd/beacon-chain"}, "build/beacon-chain"},
+	"validator":    {[]string{"go", "build", "-o", "build/validator", "./cmd/validator"}, "build/validator"},
+}
+
+// parseSourceBuilds parses the builds of the form <release>=<path>, where the path is
+// the checkout of the repository of the release (i.e. reth=../reth)
+func parseSourceBuilds(items []string) ([]*sourceBuild, error) {
+	builds := []*sourceBuild{}
+	for _, item := range items {
+		name, path, ok := strings.Cut(item, "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid build '%s', expected <release>=<path>", item)
+		}
+		if _, ok := sourceBuildCmds[name]; !ok {
+			return nil, fmt.Errorf("release '%s' cannot be built from source, it must be one of reth, lighthouse, beacon-chain or validator", name)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("source of %s not found in %s", name, abs)
+		}
+		builds = append(builds, &sourceBuild{name: name, path: abs})
+	}
+	return builds, nil
+}
+
+// build compiles the binary in its checkout and returns its path. The output of the
+// build goes to the build-<release> log.
+func (b *sourceBuild) build(out *output) (string, error) {
+	cmd := sourceBuildCmds[b.name]
+
+	logOutput, err := out.LogOutput("build-" + b.name)
+	if err != nil {
+		return "", err
+	}
+	defer logOutput.Close()
+
+	logger.Info("Building from source", "release", b.name, "path", b.path, "cmd", strings.Join(cmd.args, " "))
+	c := exec.Command(cmd.args[0], cmd.args[1:]...)
+	c.Dir = b.path
+	c.Stdout = logOutput
+	c.Stderr = logOutput
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("failed to build %s from %s (see %s): %w", b.name, b.path, logOutput.Name(), err)
+	}
+	return filepath.Join(b.path, cmd.bin), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	relayloadgen "github.com/ferranbt/builder-playground/relay-loadgen"
+)
+
+var buildersFlag uint64
+
+// mockBuilders returns the names of the relay load generators, which act as builders
+// that compete in the auctions of the relay. There is one with --relay-loadgen-rate and
+// one per builder with --builders.
+func mockBuilders() []string {
+	if buildersFlag == 0 {
+		if relayLoadgenRateFlag > 0 {
+			return []string{"relay-loadgen"}
+		}
+		return nil
+	}
+	if buildersFlag == 1 {
+		return []string{"relay-loadgen"}
+	}
+	names := []string{}
+	for i := uint64(1); i <= buildersFlag; i++ {
+		names = append(names, fmt.Sprintf("relay-loadgen-%d", i))
+	}
+	return names
+}
+
+// mockBuilderRate returns the submissions per second of each relay load generator
+func mockBuilderRate() float64 {
+	if relayLoadgenRateFlag > 0 {
+		return relayLoadgenRateFlag
+	}
+	return relayloadgen.DefaultConfig().Rate
+}
+
+// mockBuilderStats gathers the stats of the relay load generators as they stop. With
+// several builders, the stats artifact has the stats of each one by name.
+type mockBuilderStats struct {
+	lock  sync.Mutex
+	out   *output
+	stats map[string]*relayloadgen.Stats
+}
+
+func newMockBuilderStats(out *output) *mockBuilderStats {
+	return &mockBuilderStats{out: out, stats: map[string]*relayloadgen.Stats{}}
+}
+
+func (m *mockBuilderStats) write(name string, stats *relayloadgen.Stats) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if name == "relay-loadgen" {
+		return m.out.WriteFile(relayLoadgenStatsArtifact, stats)
+	}
+	m.stats[name] = stats
+	return m.out.WriteFile(relayLoadgenStatsArtifact, m.stats)
+}
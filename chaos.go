@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// chaosArtifact records the network faults of each service
+const chaosArtifact = "chaos.json"
+
+// maxChaosServices is the number of services with network faults, each one uses a band
+// of the prio qdisc (16 at most) and the first band is the traffic without faults
+const maxChaosServices = 15
+
+var chaosFlag []string
+var chaosDryRunFlag bool
+var chaosDelayFlag time.Duration
+var chaosJitterFlag time.Duration
+var chaosLossFlag string
+var chaosPauseEveryFlag time.Duration
+var chaosPauseForFlag time.Duration
+
+// serviceChaos are the faults of --chaos by service
+var serviceChaos map[string]*chaosSpec
+
+// chaosSpec are the faults injected in a service: latency and packet loss in the traffic
+// from and to its ports, and pauses of its process on a schedule
+type chaosSpec struct {
+	delay  time.Duration
+	jitter time.Duration
+	loss   float64
+
+	pauseEvery time.Duration
+	pauseFor   time.Duration
+}
+
+func (c *chaosSpec) hasNetem() bool {
+	return c.delay != 0 || c.loss != 0
+}
+
+// netemArgs returns the arguments of the netem qdisc with the faults
+func (c *chaosSpec) netemArgs() []string {
+	args := []string{"netem"}
+	if c.delay != 0 {
+		args = append(args, "delay", c.delay.String())
+		if c.jitter != 0 {
+			args = append(args, c.jitter.String())
+		}
+	}
+	if c.loss != 0 {
+		args = append(args, "loss", strconv.FormatFloat(c.loss, 'f', -1, 64)+"%")
+	}
+	return args
+}
+
+// String returns the spec in the format of --chaos
+func (c *chaosSpec) String() string {
+	items := []string{}
+	if c.delay != 0 {
+		items = append(items, "delay:"+c.delay.String())
+	}
+	if c.jitter != 0 {
+		items = append(items, "jitter:"+c.jitter.String())
+	}
+	if c.loss != 0 {
+		items = append(items, "loss:"+strconv.FormatFloat(c.loss, 'f', -1, 64)+"%")
+	}
+	if c.pauseEvery != 0 {
+		items = append(items, "pause-every:"+c.pauseEvery.String(), "pause-for:"+c.pauseFor.String())
+	}
+	return strings.Join(items, ",")
+}
+
+func (c *chaosSpec) validate() error {
+	if c.jitter != 0 && c.delay == 0 {
+		return fmt.Errorf("jitter requires a delay")
+	}
+	if c.loss < 0 || c.loss > 100 {
+		return fmt.Errorf("loss must be between 0%% and 100%%")
+	}
+	if (c.pauseEvery == 0) != (c.pauseFor == 0) {
+		return fmt.Errorf("pause-every and pause-for must be set together")
+	}
+	if c.pauseEvery != 0 && c.pauseFor >= c.pauseEvery {
+		return fmt.Errorf("pause-for must be shorter than pause-every")
+	}
+	if !c.hasNetem() && c.pauseEvery == 0 {
+		return fmt.Errorf("no faults")
+	}
+	return nil
+}
+
+// parseLoss parses a percentage of packet loss (i.e. 1% or 0.5)
+func parseLoss(s string) (float64, error) {
+	loss, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid loss '%s', expected a percentage", s)
+	}
+	return loss, nil
+}
+
+// parseChaosSpec parses the faults of the form <fault>:<value>,<fault>:<value>, where the
+// faults are delay, jitter, loss, pause-every and pause-for
+func parseChaosSpec(s string) (*chaosSpec, error) {
+	spec := &chaosSpec{}
+	for _, item := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(item, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault '%s', expected <fault>:<value>", item)
+		}
+		var err error
+		switch name {
+		case "delay":
+			spec.delay, err = time.ParseDuration(value)
+		case "jitter":
+			spec.jitter, err = time.ParseDuration(value)
+		case "loss":
+			spec.loss, err = parseLoss(value)
+		case "pause-every":
+			spec.pauseEvery, err = time.ParseDuration(value)
+		case "pause-for":
+			spec.pauseFor, err = time.ParseDuration(value)
+		default:
+			return nil, fmt.Errorf("unknown fault '%s', it must be delay, jitter, loss, pause-every or pause-for", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s '%s': %w", name, value, err)
+		}
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// parseChaos parses the faults of the form <service>=<fault>:<value>,... (i.e.
+// reth=delay:100ms,loss:1%)
+func parseChaos(items []string) (map[string]*chaosSpec, error) {
+	specs := map[string]*chaosSpec{}
+	for _, item := range items {
+		name, specStr, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid chaos '%s', expected <service>=<fault>:<value>,...", item)
+		}
+		spec, err := parseChaosSpec(specStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chaos of %s: %w", name, err)
+		}
+		specs[name] = spec
+	}
+	return specs, nil
+}
+
+// WithChaos injects the faults in the service once it starts
+func (s *service) WithChaos(spec *chaosSpec) *service {
+	s.chaos = spec
+	return s
+}
+
+// startChaos applies the network faults of the services with WithChaos and schedules
+// their pauses
+func startChaos(out *output, svcManager *serviceManager) error {
+	specs := map[string]string{}
+	ports := map[string][]int{}
+	for _, ss := range svcManager.services {
+		if ss.chaos == nil {
+			continue
+		}
+		if ss.chaos.hasNetem() {
+			specs[ss.name] = ss.chaos.String()
+			for _, p := range ss.ports {
+				ports[ss.name] = append(ports[ss.name], p.port)
+			}
+		}
+		if ss.chaos.pauseEvery != 0 {
+			svcManager.NewCronJob("chaos-"+ss.name, ss.chaos.pauseEvery, newChaosPauser(out, ss.name, ss.chaos.pauseFor))
+		}
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	if err := applyNetem(specs, ports); err != nil {
+		return err
+	}
+	return out.WriteFile(chaosArtifact, specs)
+}
+
+// stopChaos removes the network faults
+func stopChaos(out *output) {
+	if !out.Exists(chaosArtifact) {
+		return
+	}
+	if err := runTC([][]string{{"qdisc", "del", "dev", "lo", "root"}}, true); err != nil {
+		logger.Error("Error removing the network faults", "err", err)
+	}
+	out.Remove(chaosArtifact)
+}
+
+// newChaosPauser pauses the service for the duration every time it runs
+func newChaosPauser(out *output, name string, duration time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := signalServiceIn(out, name, syscall.SIGSTOP, "paused by chaos"); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(duration):
+		}
+		return signalServiceIn(out, name, syscall.SIGCONT, "resumed by chaos")
+	}
+}
+
+// applyNetem replaces the qdisc of the loopback interface with a prio qdisc that sends
+// the packets from and to the ports of each service to a band with its netem qdisc. The
+// rest of the traffic goes to the first band, without faults.
+func applyNetem(specs map[string]string, ports map[string][]int) error {
+	if len(specs) > maxChaosServices {
+		return fmt.Errorf("at most %d services can have network faults", maxChaosServices)
+	}
+	names := []string{}
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// all the priorities go to the first band
+	root := []string{"qdisc", "add", "dev", "lo", "root", "handle", "1:", "prio", "bands", "16", "priomap"}
+	for i := 0; i < 16; i++ {
+		root = append(root, "0")
+	}
+	cmds := [][]string{
+		{"qdisc", "del", "dev", "lo", "root"},
+		root,
+	}
+	for i, name := range names {
+		spec, err := parseChaosSpec(specs[name])
+		if err != nil {
+			return err
+		}
+		if len(ports[name]) == 0 {
+			return fmt.Errorf("service '%s' has no ports for the network faults", name)
+		}
+		// the class ids are hex
+		band := fmt.Sprintf("%x", i+2)
+		cmds = append(cmds, append([]string{"qdisc", "add", "dev", "lo", "parent", "1:" + band, "handle", band + "0:"}, spec.netemArgs()...))
+		for _, port := range ports[name] {
+			for _, match := range []string{"sport", "dport"} {
+				cmds = append(cmds, []string{"filter", "add", "dev", "lo", "parent", "1:", "protocol", "ip", "prio", "1", "u32", "match", "ip", match, strconv.Itoa(port), "0xffff", "flowid", "1:" + band})
+			}
+		}
+	}
+	// there is no qdisc to delete the first time
+	if err := runTC(cmds[:1], true); err != nil {
+		return err
+	}
+	return runTC(cmds[1:], false)
+}
+
+// loadChaosSpecs returns the network faults of each service applied in the output folder
+func loadChaosSpecs(out *output) (map[string]string, error) {
+	specs := map[string]string{}
+	data, err := out.ReadFile(chaosArtifact)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return specs, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// runTC runs the tc commands. If allowMissing is set, the failures because there is no
+// qdisc to delete are ignored, any other failure (i.e. not running as root) is returned.
+func runTC(cmds [][]string, allowMissing bool) error {
+	for _, args := range cmds {
+		if chaosDryRunFlag {
+			fmt.Println("tc " + strings.Join(args, " "))
+			continue
+		}
+		output, err := exec.Command("tc", args...).CombinedOutput()
+		if err == nil || (allowMissing && isMissingQdisc(string(output))) {
+			continue
+		}
+		return fmt.Errorf("tc %s failed (it requires root): %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// isMissingQdisc returns whether the output of tc reports that there is no qdisc to delete
+func isMissingQdisc(output string) bool {
+	return strings.Contains(output, "No such file or directory") || strings.Contains(output, "Cannot delete qdisc with handle of zero")
+}
+
+var chaosCmd = &cobra.Command{
+	Use:   "chaos",
+	Short: "Inject faults in the services of the playground",
+	Long:  `Inject network latency and packet loss (tc netem in the loopback interface, requires root) and pauses of the processes in the services of the playground running in the output folder. Use the partition command to split the network.`,
+}
+
+var chaosNetemCmd = &cobra.Command{
+	Use:   "netem <service>",
+	Short: "Add latency and packet loss to the traffic of a service",
+	Long:  `Add latency (--delay, --jitter) and packet loss (--loss) to the packets from and to the ports of the service. The faults of the other services are kept, use 'chaos heal' to remove all of them.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+		name := args[0]
+
+		spec := &chaosSpec{delay: chaosDelayFlag, jitter: chaosJitterFlag}
+		if chaosLossFlag != "" {
+			var err error
+			if spec.loss, err = parseLoss(chaosLossFlag); err != nil {
+				return err
+			}
+		}
+		if !spec.hasNetem() {
+			return fmt.Errorf("--delay or --loss is required")
+		}
+		if err := spec.validate(); err != nil {
+			return err
+		}
+
+		topo, err := loadTopology(out)
+		if err != nil {
+			return err
+		}
+		ports := map[string][]int{}
+		for _, node := range topo.Nodes {
+			for _, p := range node.Ports {
+				ports[node.Name] = append(ports[node.Name], p.Port)
+			}
+		}
+		if _, ok := ports[name]; !ok {
+			return fmt.Errorf("service '%s' not found in the topology or it has no ports", name)
+		}
+
+		specs, err := loadChaosSpecs(out)
+		if err != nil {
+			return err
+		}
+		specs[name] = spec.String()
+		if err := applyNetem(specs, ports); err != nil {
+			return err
+		}
+		if chaosDryRunFlag {
+			return nil
+		}
+
+		fmt.Printf("Service %s with %s\n", name, spec)
+		if err := out.WriteFile(chaosArtifact, specs); err != nil {
+			return err
+		}
+		return appendEvent(out, fmt.Sprintf("chaos %s: %s", name, spec))
+	},
+}
+
+var chaosPauseCmd = &cobra.Command{
+	Use:   "pause <service>",
+	Short: "Pause a service on a schedule",
+	Long:  `Pause the process of the service for --for every --every until the command is interrupted`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		spec := &chaosSpec{pauseEvery: chaosPauseEveryFlag, pauseFor: chaosPauseForFlag}
+		if err := spec.validate(); err != nil {
+			return err
+		}
+		pause := newChaosPauser(out, args[0], spec.pauseFor)
+		for {
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-time.After(spec.pauseEvery - spec.pauseFor):
+			}
+			if err := pause(cmd.Context()); err != nil {
+				return err
+			}
+		}
+	},
+}
+
+var chaosHealCmd = &cobra.Command{
+	Use:   "heal",
+	Short: "Remove the network faults",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		// there is nothing to heal if there is no qdisc
+		if err := runTC([][]string{{"qdisc", "del", "dev", "lo", "root"}}, true); err != nil {
+			return err
+		}
+		if chaosDryRunFlag {
+			return nil
+		}
+		if err := out.Remove(chaosArtifact); err != nil {
+			return err
+		}
+		fmt.Println("Network faults removed")
+		return appendEvent(out, "chaos healed")
+	},
+}
@@ -8,31 +8,74 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
+// maxConsecutiveFailures is the number of consecutive failed requests to a target
+// before the proxy is considered unhealthy
+const maxConsecutiveFailures = 3
+
 type Config struct {
-	LogOutput io.Writer
-	Port      uint64
-	Primary   string
-	Secondary string
+	LogOutput   io.Writer
+	Port        uint64
+	MetricsPort uint64
+	Primary     string
+	Secondary   string
+
+	// Followers are the execution nodes (by name) that receive the same requests as
+	// the secondary to follow the chain of the primary
+	Followers map[string]string
+
+	// JWTSecret authenticates the requests of the CL. If it is not set, the requests
+	// are forwarded with the token of the CL and the targets must share its secret.
+	JWTSecret string
+
+	// TargetJWTSecrets are the secrets (by target name: primary, secondary or the name of
+	// a follower) used to sign the requests to each target when JWTSecret is set
+	TargetJWTSecrets map[string]string
+
+	// Compare sends the newPayload and forkchoiceUpdated requests unmodified to the
+	// secondary and compares its responses with the ones of the primary
+	Compare bool
+
+	// Recording receives the requests of the CL and the responses of the primary, one
+	// json object per line, so that the chain can be replayed on another node
+	Recording io.Writer
 }
 
 func DefaultConfig() *Config {
 	return &Config{
-		LogOutput: os.Stdout,
-		Port:      5656,
+		LogOutput:   os.Stdout,
+		Port:        5656,
+		MetricsPort: 5657,
 	}
 }
 
 type ClProxy struct {
-	config *Config
-	log    *logrus.Entry
-	server *http.Server
+	config  *Config
+	log     *logrus.Entry
+	server  *http.Server
+	metrics *metrics
+
+	metricsServer *http.Server
+
+	// number of consecutive failed requests per target
+	failuresLock sync.Mutex
+	failures     map[string]int
+
+	jwtSecret        []byte
+	targetJWTSecrets map[string][]byte
+
+	divergences divergences
+
+	recordingLock sync.Mutex
 }
 
 func New(config *Config) (*ClProxy, error) {
@@ -40,32 +83,121 @@ func New(config *Config) (*ClProxy, error) {
 	log.Logger.SetOutput(config.LogOutput)
 
 	proxy := &ClProxy{
-		config: config,
-		log:    log,
+		config:   config,
+		log:      log,
+		metrics:  newMetrics(),
+		failures: map[string]int{},
 	}
 
-	return proxy, nil
-}
+	if config.JWTSecret != "" {
+		var err error
+		if proxy.jwtSecret, err = decodeJWTSecret(config.JWTSecret); err != nil {
+			return nil, err
+		}
+		proxy.targetJWTSecrets = map[string][]byte{}
+		for target, secret := range config.TargetJWTSecrets {
+			if proxy.targetJWTSecrets[target], err = decodeJWTSecret(secret); err != nil {
+				return nil, fmt.Errorf("target %s: %w", target, err)
+			}
+		}
+	}
 
-// Run starts the HTTP server
-func (s *ClProxy) Run() error {
 	mux := http.NewServeMux()
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.config.Port),
+	mux.HandleFunc("/", proxy.handleRequest)
+
+	proxy.server = &http.Server{
+		Addr:         fmt.Sprintf(":%d", config.Port),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		Handler:      mux,
 	}
 
-	mux.HandleFunc("/", s.handleRequest)
+	if config.MetricsPort != 0 {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", promhttp.HandlerFor(proxy.metrics.registry, promhttp.HandlerOpts{}))
+		metricsMux.HandleFunc("/health", proxy.handleHealth)
+		metricsMux.HandleFunc("/divergences", proxy.handleDivergences)
+
+		proxy.metricsServer = &http.Server{
+			Addr:         fmt.Sprintf(":%d", config.MetricsPort),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			Handler:      metricsMux,
+		}
+	}
+
+	return proxy, nil
+}
+
+// Run starts the HTTP server
+func (s *ClProxy) Run() error {
+	// each server sends a single value, nil once it is closed
+	errCh := make(chan error, 2)
+	serve := func(name string, server *http.Server, port uint64) {
+		go func() {
+			s.log.Infof("Starting %s on port %d", name, port)
+			if err := server.ListenAndServe(); err != http.ErrServerClosed {
+				errCh <- fmt.Errorf("%s error: %v", name, err)
+				return
+			}
+			errCh <- nil
+		}()
+	}
+	if s.metricsServer != nil {
+		serve("metrics server", s.metricsServer, s.config.MetricsPort)
+	}
+	serve("server", s.server, s.config.Port)
+
+	err := <-errCh
+	if err != nil {
+		// the proxy does not keep serving without the other server
+		s.server.Close()
+		if s.metricsServer != nil {
+			s.metricsServer.Close()
+		}
+	}
+	return err
+}
+
+// Healthy returns an error if any of the targets of the proxy has failed
+// the last maxConsecutiveFailures requests.
+func (s *ClProxy) Healthy() error {
+	s.failuresLock.Lock()
+	defer s.failuresLock.Unlock()
 
-	s.log.Infof("Starting server on port %d", s.config.Port)
-	if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
-		return fmt.Errorf("server error: %v", err)
+	unhealthy := []string{}
+	for target, failures := range s.failures {
+		if failures >= maxConsecutiveFailures {
+			unhealthy = append(unhealthy, target)
+		}
+	}
+	if len(unhealthy) != 0 {
+		return fmt.Errorf("targets failing: %s", strings.Join(unhealthy, ", "))
 	}
 	return nil
 }
 
+func (s *ClProxy) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if err := s.Healthy(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "OK")
+}
+
+func (s *ClProxy) trackResult(target string, err error) {
+	s.failuresLock.Lock()
+	defer s.failuresLock.Unlock()
+
+	if err != nil {
+		s.metrics.failures.WithLabelValues(target).Inc()
+		s.failures[target]++
+	} else {
+		s.failures[target] = 0
+	}
+}
+
 // Close gracefully shuts down the server
 func (s *ClProxy) Close() error {
 	s.log.Info("Shutting down server...")
@@ -78,6 +210,11 @@ func (s *ClProxy) Close() error {
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server shutdown error: %v", err)
 	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("metrics server shutdown error: %v", err)
+		}
+	}
 
 	return nil
 }
@@ -97,6 +234,14 @@ func (s *ClProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if s.jwtSecret != nil {
+		if err := validateJWT(r, s.jwtSecret); err != nil {
+			s.log.Errorf("Unauthorized request from the CL: %v", err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
@@ -119,7 +264,8 @@ func (s *ClProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	s.log.Info(fmt.Sprintf("Received request: method=%s", jsonRPCRequest.Method))
 
 	// proxy to primary and consider its response as the final response to send back to the CL
-	resp, err := s.proxy(s.config.Primary, r, data)
+	start := time.Now()
+	resp, err := s.proxy("primary", s.config.Primary, jsonRPCRequest.Method, r, data)
 	if err != nil {
 		s.log.Errorf("Error multiplexing to primary: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -138,7 +284,14 @@ func (s *ClProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(resp.StatusCode)
 	w.Write(respData)
 
-	if s.config.Secondary == "" {
+	if s.config.Recording != nil {
+		if err := s.record(start, data, respData); err != nil {
+			s.log.Errorf("Error recording request: %v", err)
+		}
+	}
+
+	targets := s.mirrorTargets()
+	if len(targets) == 0 {
 		return
 	}
 
@@ -148,6 +301,9 @@ func (s *ClProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// with --compare the secondary gets the request as sent by the CL
+	original := data
+
 	if strings.HasPrefix(jsonRPCRequest.Method, "engine_forkchoiceUpdated") {
 		// set to nil the second parameter of the forkchoiceUpdated call
 		if len(jsonRPCRequest.Params) == 1 {
@@ -164,14 +320,86 @@ func (s *ClProxy) handleRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// proxy to secondary
-	s.log.Info(fmt.Sprintf("Multiplexing request to secondary: method=%s", jsonRPCRequest.Method))
-	if _, err := s.proxy(s.config.Secondary, r, data); err != nil {
-		s.log.Errorf("Error multiplexing to secondary: %v", err)
+	// proxy to the secondary and the followers
+	for _, target := range targets {
+		if s.config.Compare && target.name == "secondary" && comparedMethod(jsonRPCRequest.Method) {
+			s.compareSecondary(jsonRPCRequest.Method, r, original, respData)
+			continue
+		}
+		s.log.Info(fmt.Sprintf("Multiplexing request to %s: method=%s", target.name, jsonRPCRequest.Method))
+		resp, err := s.proxy(target.name, target.url, jsonRPCRequest.Method, r, data)
+		if err != nil {
+			s.log.Errorf("Error multiplexing to %s: %v", target.name, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+// recordedRequest is a request of the CL and the response of the primary
+type recordedRequest struct {
+	Time     time.Time       `json:"time"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+func (s *ClProxy) record(start time.Time, request, response []byte) error {
+	// the response is not valid json if the primary failed (i.e. unauthorized)
+	if !json.Valid(response) {
+		return nil
+	}
+	data, err := json.Marshal(&recordedRequest{Time: start, Request: request, Response: response})
+	if err != nil {
+		return err
+	}
+
+	s.recordingLock.Lock()
+	defer s.recordingLock.Unlock()
+
+	_, err = s.config.Recording.Write(append(data, '\n'))
+	return err
+}
+
+type mirrorTarget struct {
+	name string
+	url  string
+}
+
+// mirrorTargets returns the targets that receive a copy of the requests sent to the primary
+func (s *ClProxy) mirrorTargets() []*mirrorTarget {
+	targets := []*mirrorTarget{}
+	if s.config.Secondary != "" {
+		targets = append(targets, &mirrorTarget{name: "secondary", url: s.config.Secondary})
+	}
+	for name, url := range s.config.Followers {
+		targets = append(targets, &mirrorTarget{name: name, url: url})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].name < targets[j].name
+	})
+	return targets
+}
+
+// proxy forwards the request to the dst url and tracks the result under the target name
+func (s *ClProxy) proxy(target string, dst string, method string, r *http.Request, data []byte) (*http.Response, error) {
+	s.metrics.requests.WithLabelValues(method, target).Inc()
+
+	start := time.Now()
+	resp, err := s.doProxy(target, dst, r, data)
+	s.metrics.latency.WithLabelValues(target).Observe(time.Since(start).Seconds())
+
+	// a wrong secret is a failure of the target even if the request got a response
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		s.log.Errorf("Request to %s rejected, the jwt secret does not match", target)
+		s.trackResult(target, fmt.Errorf("unauthorized"))
+		return resp, nil
 	}
+
+	s.trackResult(target, err)
+	return resp, err
 }
 
-func (s *ClProxy) proxy(dst string, r *http.Request, data []byte) (*http.Response, error) {
+func (s *ClProxy) doProxy(target string, dst string, r *http.Request, data []byte) (*http.Response, error) {
 	// Create a new request
 	req, err := http.NewRequest(http.MethodPost, dst, bytes.NewBuffer(data))
 	if err != nil {
@@ -180,7 +408,20 @@ func (s *ClProxy) proxy(dst string, r *http.Request, data []byte) (*http.Respons
 
 	// Copy headers. It is important since we have to copy
 	// the JWT header from the CL
-	req.Header = r.Header
+	req.Header = r.Header.Clone()
+
+	// with a secret per target, the request is signed again with the one of the target
+	if s.jwtSecret != nil {
+		secret, ok := s.targetJWTSecrets[target]
+		if !ok {
+			return nil, fmt.Errorf("no jwt secret for target %s", target)
+		}
+		token, err := newJWT(secret)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
 
 	// Perform the request
 	client := &http.Client{}
@@ -0,0 +1,176 @@
+package clproxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRecentDivergences is the number of divergences returned by the divergences endpoint
+const maxRecentDivergences = 100
+
+// Divergence is a field of the response of the secondary that does not match the
+// response of the primary for the same request
+type Divergence struct {
+	Time      time.Time `json:"time"`
+	Method    string    `json:"method"`
+	Field     string    `json:"field"`
+	Primary   string    `json:"primary"`
+	Secondary string    `json:"secondary"`
+}
+
+type divergences struct {
+	lock   sync.Mutex
+	count  uint64
+	recent []*Divergence
+}
+
+func (d *divergences) add(divs []*Divergence) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.count += uint64(len(divs))
+	d.recent = append(d.recent, divs...)
+	if len(d.recent) > maxRecentDivergences {
+		d.recent = d.recent[len(d.recent)-maxRecentDivergences:]
+	}
+}
+
+// comparedMethod returns whether the responses of the method are compared with --compare
+func comparedMethod(method string) bool {
+	return strings.HasPrefix(method, "engine_newPayload") || strings.HasPrefix(method, "engine_forkchoiceUpdated")
+}
+
+// engineResult are the fields of the responses of newPayload and forkchoiceUpdated
+// that both builders must agree on
+type engineResult struct {
+	status          string
+	latestValidHash string
+	payloadID       string
+}
+
+type payloadStatus struct {
+	Status          string  `json:"status"`
+	LatestValidHash *string `json:"latestValidHash"`
+}
+
+func parseEngineResult(method string, data []byte) (*engineResult, error) {
+	var msg struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return &engineResult{status: fmt.Sprintf("error %d: %s", msg.Error.Code, msg.Error.Message)}, nil
+	}
+
+	var status payloadStatus
+	res := &engineResult{}
+	if strings.HasPrefix(method, "engine_forkchoiceUpdated") {
+		var fcu struct {
+			PayloadStatus payloadStatus `json:"payloadStatus"`
+			PayloadID     *string       `json:"payloadId"`
+		}
+		if err := json.Unmarshal(msg.Result, &fcu); err != nil {
+			return nil, err
+		}
+		status = fcu.PayloadStatus
+		if fcu.PayloadID != nil {
+			res.payloadID = *fcu.PayloadID
+		}
+	} else if err := json.Unmarshal(msg.Result, &status); err != nil {
+		return nil, err
+	}
+
+	res.status = status.Status
+	if status.LatestValidHash != nil {
+		res.latestValidHash = *status.LatestValidHash
+	}
+	return res, nil
+}
+
+// compareEngineResponses returns the fields that differ between the responses
+func compareEngineResponses(method string, primary, secondary []byte) ([]*Divergence, error) {
+	a, err := parseEngineResult(method, primary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from primary: %w", err)
+	}
+	b, err := parseEngineResult(method, secondary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response from secondary: %w", err)
+	}
+
+	now := time.Now()
+	divs := []*Divergence{}
+	for _, field := range []struct {
+		name string
+		a, b string
+	}{
+		{"status", a.status, b.status},
+		{"latestValidHash", a.latestValidHash, b.latestValidHash},
+		{"payloadId", a.payloadID, b.payloadID},
+	} {
+		if field.a != field.b {
+			divs = append(divs, &Divergence{Time: now, Method: method, Field: field.name, Primary: field.a, Secondary: field.b})
+		}
+	}
+	return divs, nil
+}
+
+// compareSecondary sends the request of the CL unmodified to the secondary and compares
+// its response with the one of the primary
+func (s *ClProxy) compareSecondary(method string, r *http.Request, data []byte, primaryResp []byte) {
+	s.log.Info(fmt.Sprintf("Comparing request with secondary: method=%s", method))
+	resp, err := s.proxy("secondary", s.config.Secondary, method, r, data)
+	if err != nil {
+		s.log.Errorf("Error multiplexing to secondary: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.log.Errorf("Error reading response from secondary: %v", err)
+		return
+	}
+
+	divs, err := compareEngineResponses(method, primaryResp, respData)
+	if err != nil {
+		s.log.Errorf("Error comparing the responses of %s: %v", method, err)
+		return
+	}
+	for _, div := range divs {
+		s.log.Warnf("Divergence in %s: %s primary=%q secondary=%q", method, div.Field, div.Primary, div.Secondary)
+		s.metrics.divergences.WithLabelValues(method, div.Field).Inc()
+	}
+	s.divergences.add(divs)
+}
+
+// Divergences returns the number of divergences found with --compare and the most recent ones
+func (s *ClProxy) Divergences() (uint64, []*Divergence) {
+	s.divergences.lock.Lock()
+	defer s.divergences.lock.Unlock()
+
+	recent := make([]*Divergence, len(s.divergences.recent))
+	copy(recent, s.divergences.recent)
+	return s.divergences.count, recent
+}
+
+func (s *ClProxy) handleDivergences(w http.ResponseWriter, r *http.Request) {
+	count, recent := s.Divergences()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":  count,
+		"recent": recent,
+	})
+}
@@ -0,0 +1,69 @@
+package clproxy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// jwtMaxSkew is the maximum difference between the issued-at claim of a token and the
+// current time, as in the authentication of the engine api
+const jwtMaxSkew = 60 * time.Second
+
+func decodeJWTSecret(secret string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(secret), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt secret: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid jwt secret: expected 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// validateJWT checks the token of the Authorization header of the request with the secret
+func validateJWT(r *http.Request, secret []byte) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return fmt.Errorf("missing jwt token")
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(strings.TrimPrefix(auth, "Bearer "), &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid jwt token: %w", err)
+	}
+	if claims.IssuedAt == nil {
+		return fmt.Errorf("jwt token without iat claim")
+	}
+	if skew := time.Since(claims.IssuedAt.Time); skew > jwtMaxSkew || skew < -jwtMaxSkew {
+		return fmt.Errorf("stale jwt token (iat %s)", claims.IssuedAt.Time)
+	}
+	return nil
+}
+
+// newJWT returns a token for the engine api signed with the secret
+func newJWT(secret []byte) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	})
+	return token.SignedString(secret)
+}
+
+// NewJWTToken returns a token for the engine api signed with the hex encoded secret
+func NewJWTToken(secret string) (string, error) {
+	key, err := decodeJWTSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return newJWT(key)
+}
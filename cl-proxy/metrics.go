@@ -0,0 +1,38 @@
+package clproxy
+
+import "github.com/prometheus/client_golang/prometheus"
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	failures *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+
+	divergences *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clproxy_requests_total",
+			Help: "Number of requests forwarded by method and target",
+		}, []string{"method", "target"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clproxy_request_failures_total",
+			Help: "Number of failed requests by target",
+		}, []string{"target"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "clproxy_request_duration_seconds",
+			Help:    "Latency of the forwarded requests by target",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"target"}),
+		divergences: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "clproxy_divergences_total",
+			Help: "Number of fields of the responses of the secondary that differ from the primary by method and field",
+		}, []string{"method", "field"}),
+	}
+	m.registry.MustRegister(m.requests, m.failures, m.latency, m.divergences)
+	return m
+}
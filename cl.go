@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// consensus clients that can run the beacon node and the validator
+const (
+	clLighthouse = "lighthouse"
+	clPrysm      = "prysm"
+)
+
+var clClientFlag string
+var prysmVersionFlag string
+
+// prysmImportJob imports the validator keystores into the wallet of the prysm validator
+const prysmImportJob = "validator-import"
+
+// prysmWalletPassword is the password of the wallets of the prysm validators. It must have
+// at least 8 characters, the keystores are encrypted with the common secret.
+const prysmWalletPassword = "playground"
+
+// clReleases are the release binaries of each consensus client
+var clReleases = map[string][]string{
+	clLighthouse: {"lighthouse"},
+	clPrysm:      {"beacon-chain", "validator"},
+}
+
+// releaseNames returns the release binaries required by the consensus client and the
+// validator clients
+func releaseNames() []string {
+	names := append([]string{"reth"}, clReleases[clClientFlag]...)
+	for _, vc := range validatorClients() {
+		if name := validatorClientRelease[vc.client]; !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func validateCLClient() error {
+	if _, ok := clReleases[clClientFlag]; !ok {
+		return fmt.Errorf("unknown --cl-client '%s', it must be one of %s or %s", clClientFlag, clLighthouse, clPrysm)
+	}
+	if clClientFlag == clPrysm && networkFlag != "" {
+		return fmt.Errorf("--cl-client %s is only supported in the local devnet", clPrysm)
+	}
+	return nil
+}
+
+// clArtifacts returns the artifacts of the validator clients that are not shared with the
+// other clients
+func clArtifacts() map[string]interface{} {
+	artifacts := map[string]interface{}{}
+	for _, vc := range validatorClients() {
+		if vc.client == clPrysm {
+			artifacts[filepath.Join(vc.prysmDir(), "wallet_password")] = prysmWalletPassword
+			artifacts[filepath.Join(vc.prysmDir(), "account_password")] = secret
+		}
+	}
+	return artifacts
+}
+
+// runConsensusClient starts the beacon node of the consensus client and the validator
+// clients. Both clients expose the beacon api in the same port so the rest of the services
+// do not depend on the client.
+func runConsensusClient(svcManager *serviceManager, bins map[string]string) error {
+	var err error
+	if clClientFlag == clPrysm {
+		err = runPrysm(svcManager, bins["beacon-chain"])
+	} else {
+		err = runLighthouse(svcManager, bins["lighthouse"])
+	}
+	if err != nil {
+		return err
+	}
+	return runValidators(svcManager, bins)
+}
+
+func runLighthouse(svcManager *serviceManager, lighthouseBin string) error {
+	lightHouseVersion := func() string {
+		cmd := exec.Command(lighthouseBin, "--version")
+		out, err := cmd.Output()
+		if err != nil {
+			return "unknown"
+		}
+		// find the line of the form:
+		// Lighthouse v5.2.1-9e12c21
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.HasPrefix(line, "Lighthouse ") {
+				v := strings.TrimSpace(strings.TrimPrefix(line, "Lighthouse "))
+				if !strings.HasPrefix(v, "v") {
+					v = "v" + v
+				}
+				// Go semver considers - as a pre-release, so we need to remove it
+				v = strings.Split(v, "-")[0]
+				return semver.Canonical(v)
+			}
+		}
+		return "unknown"
+	}()
+
+	// start the beacon node
+	logger.Info("Starting lighthouse", "version", lightHouseVersion)
+	if err := checkComponentVersion("lighthouse", lightHouseVersion); err != nil {
+		return err
+	}
+	svcManager.
+		NewService("beacon_node").
+		WithArgs(
+			lighthouseBin,
+			"bn",
+			"--datadir", "{{.Dir}}/data_beacon_node",
+			"--staking",
+			"--enr-udp-port", "9000",
+			"--enr-tcp-port", "9000",
+			"--enr-quic-port", "9100",
+			"--port", "9000",
+			"--quic-port", "9100",
+			"--http-port", "3500",
+			"--execution-endpoint", "http://localhost:5656",
+			"--execution-jwt", "{{.Dir}}/"+engineJWTArtifact(jwtConnBeaconNode),
+			"--builder", "http://localhost:5555",
+			"--always-prepare-payload",
+			"--prepare-payload-lookahead", "8000",
+		).
+		If(
+			networkFlag == "",
+			func(s *service) *service {
+				// local devnet without any other peers
+				return s.WithArgs(
+					"--testnet-dir", "{{.Dir}}/testnet",
+					"--enable-private-discovery",
+					"--disable-peer-scoring",
+					"--enr-address", "127.0.0.1",
+					"--disable-packet-filter",
+					"--target-peers", "0",
+					"--builder-fallback-epochs-since-finalization", "0",
+					"--builder-fallback-disable-checks",
+				)
+			},
+		).
+		If(
+			networkFlag != "",
+			func(s *service) *service {
+				return s.WithArgs(
+					"--network", networkFlag,
+					"--checkpoint-sync-url", checkpointSyncURLFlag,
+				)
+			},
+		).
+		WithVersionArgs("lighthouse", lightHouseVersion).
+		WithPort("http", 3500, protocolHTTP).
+		WithDependency("cl-proxy", "jsonrpc", dependencyEngineAPI).
+		WithDependency("mev-boost-relay", "http", dependencyBuilderAPI).
+		Run()
+
+	return nil
+}
+
+// prysmVersion returns the version of the prysm binary. The output is of the form:
+// beacon-chain-v5.1.2-d51b6a5f Built at: 2024-10-08 14:12:15+00:00
+func prysmVersion(bin string) string {
+	out, err := exec.Command(bin, "--version").Output()
+	if err != nil {
+		return "unknown"
+	}
+	for _, field := range strings.Fields(string(out)) {
+		idx := strings.Index(field, "-v")
+		if idx == -1 {
+			continue
+		}
+		// Go semver considers - as a pre-release, so we need to remove it
+		v := strings.Split(field[idx+1:], "-")[0]
+		if semver.IsValid(v) {
+			return semver.Canonical(v)
+		}
+	}
+	return "unknown"
+}
+
+func runPrysm(svcManager *serviceManager, beaconBin string) error {
+	version := prysmVersion(beaconBin)
+
+	logger.Info("Starting prysm", "version", version)
+	if err := checkComponentVersion("prysm", version); err != nil {
+		return err
+	}
+	svcManager.
+		NewService("beacon_node").
+		WithArgs(
+			beaconBin,
+			"--accept-terms-of-use",
+			"--datadir", "{{.Dir}}/data_beacon_node_prysm",
+			"--chain-config-file", "{{.Dir}}/testnet/config.yaml",
+			"--genesis-state", "{{.Dir}}/testnet/genesis.ssz",
+			"--contract-deployment-block", "0",
+			"--p2p-tcp-port", "9000",
+			"--p2p-udp-port", "9000",
+			"--p2p-quic-port", "9100",
+			"--p2p-static-id",
+			"--no-discovery",
+			"--min-sync-peers", "0",
+			"--http-port", "3500",
+			"--rpc-port", "4000",
+			"--execution-endpoint", "http://localhost:5656",
+			"--jwt-secret", "{{.Dir}}/"+engineJWTArtifact(jwtConnBeaconNode),
+			"--http-mev-relay", "http://localhost:5555",
+			// the relay is the only source of blocks, do not fall back to the local ones
+			// after a few missed slots
+			"--max-builder-consecutive-missed-slots", "1000",
+			"--max-builder-epoch-missed-slots", "1000",
+			"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990",
+		).
+		WithVersionArgs("prysm", version).
+		WithPort("http", 3500, protocolHTTP).
+		WithPort("rpc", 4000, protocolGRPC).
+		WithDependency("cl-proxy", "jsonrpc", dependencyEngineAPI).
+		WithDependency("mev-boost-relay", "http", dependencyBuilderAPI).
+		Run()
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// registerCompletions adds the dynamic shell completions of the arguments and flags.
+// The static completion script is generated with the 'completion' command of cobra.
+func registerCompletions() {
+	pauseCmd.ValidArgsFunction = completeRunningServices
+	resumeCmd.ValidArgsFunction = completeRunningServices
+	forwardCmd.ValidArgsFunction = completeServicePorts
+	inspectCmd.ValidArgsFunction = completeSessionServices
+
+	for _, cmd := range []*cobra.Command{rootCmd, testCmd, matrixCmd} {
+		cmd.RegisterFlagCompletionFunc("feature", completeFeatures)
+		cmd.RegisterFlagCompletionFunc("network", completeNetworks)
+	}
+}
+
+// completeRunningServices completes the names of the services running in the
+// output folder (the ones with a pid file).
+func completeRunningServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if err := resolveOutputFlag(); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	files, err := os.ReadDir(filepath.Join(outputFlag, "pids"))
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := []string{}
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".pid")
+		if ok && strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeServicePorts completes the ports of the services in the topology of the output
+// folder as <service>:<port>
+func completeServicePorts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if err := resolveOutputFlag(); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	topo, err := loadTopology(&output{dst: outputFlag})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	targets := []string{}
+	for _, node := range topo.Nodes {
+		for _, p := range node.Ports {
+			if target := node.Name + ":" + p.Name; strings.HasPrefix(target, toComplete) {
+				targets = append(targets, target+"\t"+p.URL)
+			}
+		}
+	}
+	return targets, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSessionServices completes the id of a running session and then the names of
+// the services in its topology
+func completeSessionServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		sessions, err := listSessions()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		ids := []string{}
+		for _, s := range sessions {
+			if strings.HasPrefix(s.ID, toComplete) {
+				ids = append(ids, s.ID+"\t"+s.Output)
+			}
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		if err := resolveSessionOutput(args[0]); err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		topo, err := loadTopology(&output{dst: outputFlag})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		names := []string{}
+		for _, node := range topo.Nodes {
+			if strings.HasPrefix(node.Name, toComplete) {
+				names = append(names, node.Name)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeFeatures(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names := []string{}
+	for name, description := range knownFeatures {
+		names = append(names, name+"\t"+description)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeNetworks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	names := []string{}
+	for name := range checkpointSyncURLs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
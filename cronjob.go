@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// NewCronJob runs fn every interval while the services are running. Each failure is
+// written in the log of the job, and the console is notified when the job starts
+// failing and when it recovers.
+func (s *serviceManager) NewCronJob(name string, interval time.Duration, fn func(ctx context.Context) error) {
+	logOutput, err := s.out.LogOutput(name)
+	if err != nil {
+		// this should not happen, log it
+		logger.Error("Error creating the log output", "job", name, "err", err)
+		logOutput = os.Stdout
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var failures uint64
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := fn(s.ctx); err != nil {
+				if s.ctx.Err() != nil {
+					// the job was cancelled while running
+					return
+				}
+				fmt.Fprintf(logOutput, "%s: %v\n", time.Now().Format(time.RFC3339), err)
+				if failures == 0 {
+					logger.Warn("Job failed", "job", name, "err", err)
+					emitProgress(&progressEvent{Type: progressCronFailing, Service: name, Message: err.Error()})
+				}
+				failures++
+			} else {
+				if failures != 0 {
+					logger.Info("Job recovered", "job", name, "failures", failures)
+					emitProgress(&progressEvent{Type: progressCronRecovered, Service: name})
+				}
+				failures = 0
+			}
+		}
+	}()
+}
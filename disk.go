@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var maxDiskFlag string
+
+// diskWarnThresholds are the fractions of the disk quota at which a warning is printed
+var diskWarnThresholds = []float64{0.5, 0.75, 0.9}
+
+// minFreeDisk is the free space of the disk of the output folder below which a
+// warning is printed, with or without a quota
+const minFreeDisk = 5 << 30
+
+var sizeUnits = map[string]uint64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+}
+
+// parseSize parses a size with a unit (i.e. 50GB). The units are powers of 1024.
+func parseSize(s string) (uint64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	num := strings.TrimRight(str, "KMGTB")
+	unit := str[len(num):]
+
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid size '%s', the unit must be one of B, KB, MB, GB or TB", s)
+	}
+	val, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || val <= 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+	return uint64(val * float64(mult)), nil
+}
+
+func formatSize(size uint64) string {
+	return fmt.Sprintf("%.1fGB", float64(size)/(1<<30))
+}
+
+// dirSize returns the size of the files in the folder. The files removed while
+// walking the folder (i.e. by the services) are skipped.
+func dirSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size, err
+}
+
+// freeDisk returns the space available to the user in the disk of the path
+func freeDisk(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// newDiskWatcher warns when the output folder crosses the thresholds of the quota or the
+// disk is running out of space. If the quota is exceeded, it calls stop.
+func newDiskWatcher(out *output, quota uint64, stop func()) func(ctx context.Context) error {
+	crossed := 0
+	lowFree := false
+
+	warn := func(msg string) error {
+		logger.Warn(msg)
+		return appendEvent(out, msg)
+	}
+
+	return func(ctx context.Context) error {
+		size, err := dirSize(out.dst)
+		if err != nil {
+			return err
+		}
+
+		if quota != 0 {
+			for crossed < len(diskWarnThresholds) && float64(size) >= diskWarnThresholds[crossed]*float64(quota) {
+				msg := fmt.Sprintf("output folder uses %s, %.0f%% of the disk quota (%s)", formatSize(size), diskWarnThresholds[crossed]*100, formatSize(quota))
+				if err := warn(msg); err != nil {
+					return err
+				}
+				crossed++
+			}
+			if size >= quota {
+				if err := warn(fmt.Sprintf("output folder uses %s, over the disk quota (%s), stopping", formatSize(size), formatSize(quota))); err != nil {
+					return err
+				}
+				stop()
+				return nil
+			}
+		}
+
+		free, err := freeDisk(out.dst)
+		if err != nil {
+			return err
+		}
+		if free < minFreeDisk && !lowFree {
+			if err := warn(fmt.Sprintf("only %s of free disk space left (output folder uses %s)", formatSize(free), formatSize(size))); err != nil {
+				return err
+			}
+		}
+		lowFree = free < minFreeDisk
+		return nil
+	}
+}
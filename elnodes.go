@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+var numELNodesFlag int
+
+// rethPortOffset is the offset of the http and authrpc ports between the reth nodes
+const rethPortOffset = 10
+
+// rethFollower is an additional reth node. It peers with the first node and follows its
+// chain with the engine api calls of the beacon node mirrored by the cl-proxy.
+type rethFollower struct {
+	name  string
+	index int
+}
+
+// rethFollowers returns the additional reth nodes (reth-2...reth-N)
+func rethFollowers() []*rethFollower {
+	followers := []*rethFollower{}
+	for i := 1; i < numELNodesFlag; i++ {
+		followers = append(followers, &rethFollower{name: fmt.Sprintf("reth-%d", i+1), index: i})
+	}
+	return followers
+}
+
+func (r *rethFollower) HTTPPort() int {
+	return 8545 + r.index*rethPortOffset
+}
+
+func (r *rethFollower) AuthRPCPort() int {
+	return 8551 + r.index*rethPortOffset
+}
+
+func (r *rethFollower) P2PPort() int {
+	return 30303 + r.index
+}
+
+func (r *rethFollower) P2PKeyArtifact() string {
+	return "keys/" + r.name + "_p2p"
+}
+
+// RethFollowerP2PKey derives the p2p key of a follower from the key of the first node,
+// so that its enode address is stable across restarts.
+func (k *keyRegistry) RethFollowerP2PKey(index int) string {
+	var idx [8]byte
+	binary.BigEndian.PutUint64(idx[:], uint64(index))
+	return hex.EncodeToString(ecrypto.Keccak256([]byte(k.rethP2PKey), idx[:]))
+}
+
+// RethFollowerEnode returns the enode address of a follower
+func (k *keyRegistry) RethFollowerEnode(f *rethFollower) (string, error) {
+	priv, err := getPrivKey(k.RethFollowerP2PKey(f.index))
+	if err != nil {
+		return "", err
+	}
+	return enode.NewV4(&priv.PublicKey, net.IPv4(127, 0, 0, 1), f.P2PPort(), f.P2PPort()).URLv4(), nil
+}
+
+// RethEnode returns the enode address of the first reth node
+func (k *keyRegistry) RethEnode() (string, error) {
+	priv, err := getPrivKey(k.rethP2PKey)
+	if err != nil {
+		return "", err
+	}
+	return enode.NewV4(&priv.PublicKey, net.IPv4(127, 0, 0, 1), 30303, 30303).URLv4(), nil
+}
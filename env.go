@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// proxyEnvVars are always passed to the services so that they work behind a proxy
+var proxyEnvVars = []string{
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"http_proxy", "https_proxy", "no_proxy",
+}
+
+// baseEnvVars are the minimum environment variables required to run the services
+var baseEnvVars = []string{"PATH", "HOME", "USER", "TMPDIR"}
+
+// serviceEnv returns the environment for the process of the service.
+// If no passthrough list is provided, the services inherit the full environment
+// of the playground. Otherwise, only the base, proxy and passthrough variables are set.
+// Each item of the passthrough list is either 'VAR' (for all the services) or 'service:VAR'.
+func serviceEnv(name string, passthrough []string, extra []string) []string {
+	if len(passthrough) == 0 {
+		return append(os.Environ(), extra...)
+	}
+
+	env := []string{}
+	for _, envName := range passthroughEnvNames(name, passthrough) {
+		if val, ok := os.LookupEnv(envName); ok {
+			env = append(env, envName+"="+val)
+		}
+	}
+	return append(env, extra...)
+}
+
+// passthroughEnvNames returns the names of the variables of the playground passed to the
+// service, or nil if it inherits the full environment
+func passthroughEnvNames(name string, passthrough []string) []string {
+	if len(passthrough) == 0 {
+		return nil
+	}
+
+	names := append([]string{}, baseEnvVars...)
+	names = append(names, proxyEnvVars...)
+	for _, item := range passthrough {
+		if svc, envName, ok := strings.Cut(item, ":"); ok {
+			if svc == name {
+				names = append(names, envName)
+			}
+		} else {
+			names = append(names, item)
+		}
+	}
+	return names
+}
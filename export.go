@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+// formats of the chain export
+const (
+	exportJSONL   = "jsonl"
+	exportParquet = "parquet"
+)
+
+// exportDir is the folder of the output folder with the exported chain data
+const exportDir = "export"
+
+var exportFromFlag uint64
+var exportToFlag string
+var exportFormatFlag string
+
+var exportChainCmd = &cobra.Command{
+	Use:   "export-chain [session]",
+	Short: "Export the blocks of the chain to files for offline analysis",
+	Long:  `Export the execution blocks and receipts (from the RPC of reth) and the beacon blocks (from the API of the beacon node) between --from and --to of the playground running in the output folder (or the session with the given id, see ls) to the export folder of the output folder. The range applies to the block numbers of the execution chain and to the slots of the beacon chain.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportFormatFlag == exportParquet {
+			return fmt.Errorf("the parquet format is not supported yet, use %s", exportJSONL)
+		}
+		if exportFormatFlag != exportJSONL {
+			return fmt.Errorf("unknown --format '%s', it must be %s", exportFormatFlag, exportJSONL)
+		}
+		var to *uint64
+		if exportToFlag != "head" {
+			num, err := strconv.ParseUint(exportToFlag, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid --to '%s', expected a number or head", exportToFlag)
+			}
+			if num < exportFromFlag {
+				return fmt.Errorf("--to must not be lower than --from")
+			}
+			to = &num
+		}
+
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		} else if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		endpoints, err := loadEndpoints(out)
+		if err != nil {
+			return err
+		}
+		elURL, ok := endpoints["reth"]["http"]
+		if !ok {
+			return fmt.Errorf("reth http endpoint not found in %s", out.dst)
+		}
+		clURL, ok := endpoints["beacon_node"]["http"]
+		if !ok {
+			return fmt.Errorf("beacon_node http endpoint not found in %s", out.dst)
+		}
+
+		dir := filepath.Join(out.dst, exportDir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		ctx := cmd.Context()
+
+		head, err := fetchBlockNumber(ctx, elURL)
+		if err != nil {
+			return fmt.Errorf("failed to get the head block of reth: %w", err)
+		}
+		blocks, receipts, err := exportExecutionBlocks(ctx, elURL, dir, exportFromFlag, exportRangeEnd(to, head))
+		if err != nil {
+			return err
+		}
+
+		headSlot, err := fetchHeadSlot(ctx, clURL)
+		if err != nil {
+			return fmt.Errorf("failed to get the head slot of the beacon node: %w", err)
+		}
+		beaconBlocks, err := exportBeaconBlocks(ctx, clURL, dir, exportFromFlag, exportRangeEnd(to, headSlot))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Exported %d execution blocks, %d receipts and %d beacon blocks to %s\n", blocks, receipts, beaconBlocks, dir)
+		return nil
+	},
+}
+
+// exportRangeEnd returns the end of the range, which is the head if --to is not set or
+// it is past the head
+func exportRangeEnd(to *uint64, head uint64) uint64 {
+	if to == nil || *to > head {
+		return head
+	}
+	return *to
+}
+
+// exportExecutionBlocks writes the blocks (with their transactions) and the receipts
+// between from and to, one json object per line
+func exportExecutionBlocks(ctx context.Context, url, dir string, from, to uint64) (int, int, error) {
+	blocksFile, err := newJSONLWriter(filepath.Join(dir, "execution_blocks.jsonl"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer blocksFile.Close()
+	receiptsFile, err := newJSONLWriter(filepath.Join(dir, "receipts.jsonl"))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer receiptsFile.Close()
+
+	var numBlocks, numReceipts int
+	for num := from; num <= to; num++ {
+		blockNum := "0x" + strconv.FormatUint(num, 16)
+
+		var block json.RawMessage
+		if err := rpcCall(ctx, url, "eth_getBlockByNumber", []interface{}{blockNum, true}, &block); err != nil {
+			return 0, 0, fmt.Errorf("failed to get block %d: %w", num, err)
+		}
+		if err := blocksFile.Write(block); err != nil {
+			return 0, 0, err
+		}
+		numBlocks++
+
+		var receipts []json.RawMessage
+		if err := rpcCall(ctx, url, "eth_getBlockReceipts", []interface{}{blockNum}, &receipts); err != nil {
+			return 0, 0, fmt.Errorf("failed to get the receipts of block %d: %w", num, err)
+		}
+		for _, receipt := range receipts {
+			if err := receiptsFile.Write(receipt); err != nil {
+				return 0, 0, err
+			}
+		}
+		numReceipts += len(receipts)
+	}
+	if err := blocksFile.Close(); err != nil {
+		return 0, 0, err
+	}
+	return numBlocks, numReceipts, receiptsFile.Close()
+}
+
+// exportBeaconBlocks writes the beacon blocks of the slots between from and to, one json
+// object per line. The slots without a block are skipped.
+func exportBeaconBlocks(ctx context.Context, url, dir string, from, to uint64) (int, error) {
+	blocksFile, err := newJSONLWriter(filepath.Join(dir, "beacon_blocks.jsonl"))
+	if err != nil {
+		return 0, err
+	}
+	defer blocksFile.Close()
+
+	var numBlocks int
+	for slot := from; slot <= to; slot++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/eth/v2/beacon/blocks/%d", url, slot), nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := healthClient.Do(req)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the block of slot %d: %w", slot, err)
+		}
+		var block json.RawMessage
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&block)
+		} else if resp.StatusCode != http.StatusNotFound {
+			err = fmt.Errorf("status code %d", resp.StatusCode)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get the block of slot %d: %w", slot, err)
+		}
+		if block == nil {
+			// missed slot
+			continue
+		}
+		if err := blocksFile.Write(block); err != nil {
+			return 0, err
+		}
+		numBlocks++
+	}
+	return numBlocks, blocksFile.Close()
+}
+
+// jsonlWriter writes json objects to a file, one per line
+type jsonlWriter struct {
+	file   *os.File
+	buf    *bufio.Writer
+	closed bool
+}
+
+func newJSONLWriter(path string) (*jsonlWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlWriter{file: file, buf: bufio.NewWriter(file)}, nil
+}
+
+func (w *jsonlWriter) Write(obj json.RawMessage) error {
+	// the responses can be indented
+	var line bytes.Buffer
+	if err := json.Compact(&line, obj); err != nil {
+		return err
+	}
+	line.WriteByte('\n')
+	_, err := w.buf.Write(line.Bytes())
+	return err
+}
+
+// Close flushes the file, it can be called more than once
+func (w *jsonlWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// fetchBlockNumber returns the number of the head block of the execution node
+func fetchBlockNumber(ctx context.Context, url string) (uint64, error) {
+	var blockNumber string
+	if err := rpcCall(ctx, url, "eth_blockNumber", nil, &blockNumber); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(blockNumber, 0, 64)
+}
+
+// fetchHeadSlot returns the slot of the head block of the beacon node
+func fetchHeadSlot(ctx context.Context, url string) (uint64, error) {
+	var header struct {
+		Data struct {
+			Header struct {
+				Message struct {
+					Slot string `json:"slot"`
+				} `json:"message"`
+			} `json:"header"`
+		} `json:"data"`
+	}
+	if err := httpGetJSON(ctx, url+"/eth/v1/beacon/headers/head", &header); err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(header.Data.Header.Message.Slot, 10, 64)
+}
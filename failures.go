@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// failureSnippetLines is the maximum number of lines of the log printed when a service fails
+const failureSnippetLines = 10
+
+// failureScanLines is the number of lines at the end of the log searched for the errors
+const failureScanLines = 200
+
+// failurePattern matches the log lines that report an error in any of the clients
+var failurePattern = regexp.MustCompile(`(?i)\b(error|fatal|panic|failed|crit)`)
+
+// failureSnippet returns the lines of the log of the service that explain why it failed:
+// the last lines with an error or, if there are none, the last lines of the log.
+func failureSnippet(out *output, name string) []string {
+	lines, err := tailFile(filepath.Join(out.dst, "logs", name+".log"), failureScanLines)
+	if err != nil {
+		return nil
+	}
+
+	snippet := []string{}
+	for _, line := range lines {
+		if failurePattern.MatchString(line) {
+			snippet = append(snippet, line)
+		}
+	}
+	if len(snippet) == 0 {
+		snippet = lines
+	}
+	if len(snippet) > failureSnippetLines {
+		snippet = snippet[len(snippet)-failureSnippetLines:]
+	}
+	return snippet
+}
+
+// reportFailure logs the snippet of the log of the failed service and records the
+// failure in the events log. It returns the snippet.
+func (s *serviceManager) reportFailure(name string, exitCode int) string {
+	snippet := failureSnippet(s.out, name)
+	if len(snippet) == 0 {
+		return ""
+	}
+
+	logger.Error("Service failed", "service", name, "exit_code", exitCode, "last_errors", snippet)
+	if err := appendEvent(s.out, fmt.Sprintf("service %s failed with exit code %d: %s", name, exitCode, snippet[len(snippet)-1])); err != nil {
+		logger.Error("Error writing the event of the service", "service", name, "err", err)
+	}
+	return strings.Join(snippet, "\n")
+}
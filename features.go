@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// features that change the configuration of the components
+const (
+	featureElectra        = "electra"
+	featureRethValidation = "reth-validation"
+	featureLowResources   = "low-resources"
+	featureSplitJWT       = "split-jwt"
+)
+
+var knownFeatures = map[string]string{
+	featureElectra:        "enable the Electra fork at genesis",
+	featureRethValidation: "validate the builder submissions of the relay with reth",
+	featureLowResources:   "run reth as a pruned node with less logging",
+	featureSplitJWT:       "use a different jwt secret for each engine api connection",
+}
+
+var featuresFlag []string
+
+// featureSet is the set of features enabled for the session. The components
+// query it to adjust their arguments.
+type featureSet map[string]bool
+
+func (f featureSet) Enable(name string) {
+	f[name] = true
+}
+
+func (f featureSet) Enabled(name string) bool {
+	return f[name]
+}
+
+func (f featureSet) String() string {
+	names := []string{}
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// features are the features enabled for the current session
+var features = featureSet{}
+
+// resolveFeatures builds the feature set from --feature and the flags of the
+// individual features (i.e. --electra).
+func resolveFeatures() error {
+	features = featureSet{}
+	for _, name := range featuresFlag {
+		if _, ok := knownFeatures[name]; !ok {
+			return fmt.Errorf("unknown feature '%s', available features: %s", name, featureNames())
+		}
+		features.Enable(name)
+	}
+	if latestForkFlag {
+		features.Enable(featureElectra)
+	}
+	if useRethForValidation {
+		features.Enable(featureRethValidation)
+	}
+	return nil
+}
+
+func featureNames() string {
+	names := []string{}
+	for name := range knownFeatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func featuresHelp() string {
+	help := []string{}
+	for _, name := range strings.Split(featureNames(), ", ") {
+		help = append(help, fmt.Sprintf("%s (%s)", name, knownFeatures[name]))
+	}
+	return "enable a feature: " + strings.Join(help, ", ")
+}
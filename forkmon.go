@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed forkmon.html
+var forkmonPage []byte
+
+// forkmonPort is the port of the chain monitor dashboard
+const forkmonPort = 5560
+
+// forkmonHistory is the number of block hashes kept per node to detect reorgs
+const forkmonHistory = 64
+
+type forkmonNode struct {
+	Name        string    `json:"name"`
+	URL         string    `json:"url"`
+	HeadNumber  uint64    `json:"head_number"`
+	HeadHash    string    `json:"head_hash"`
+	PeerCount   uint64    `json:"peer_count"`
+	Reorgs      uint64    `json:"reorgs"`
+	MaxReorg    uint64    `json:"max_reorg_depth"`
+	LastUpdated time.Time `json:"last_updated"`
+	Error       string    `json:"error,omitempty"`
+
+	// hashes of the last blocks seen by number
+	hashes map[uint64]string
+}
+
+// forkmon polls the head and the peers of each execution node and tracks the reorgs
+type forkmon struct {
+	lock  sync.Mutex
+	nodes []*forkmonNode
+}
+
+func newForkmon(endpoints map[string]string) *forkmon {
+	names := []string{}
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	f := &forkmon{}
+	for _, name := range names {
+		f.nodes = append(f.nodes, &forkmonNode{Name: name, URL: endpoints[name], hashes: map[uint64]string{}})
+	}
+	return f
+}
+
+type rpcBlockHeader struct {
+	Number     string `json:"number"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+}
+
+func (f *forkmon) Update(ctx context.Context) error {
+	var failed []string
+	for _, node := range f.nodes {
+		err := f.updateNode(ctx, node)
+
+		f.lock.Lock()
+		if err != nil {
+			node.Error = err.Error()
+			failed = append(failed, node.Name)
+		} else {
+			node.Error = ""
+		}
+		f.lock.Unlock()
+	}
+	if len(failed) != 0 {
+		return fmt.Errorf("failed to query %v", failed)
+	}
+	return nil
+}
+
+func (f *forkmon) updateNode(ctx context.Context, node *forkmonNode) error {
+	var head rpcBlockHeader
+	if err := rpcCall(ctx, node.URL, "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+		return err
+	}
+	number, err := strconv.ParseUint(head.Number, 0, 64)
+	if err != nil {
+		return err
+	}
+	var peerCount string
+	if err := rpcCall(ctx, node.URL, "net_peerCount", nil, &peerCount); err != nil {
+		return err
+	}
+	peers, err := strconv.ParseUint(peerCount, 0, 64)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	// the head (or its parent) replaces a block we have seen before
+	var depth uint64
+	if hash, ok := node.hashes[number]; ok && hash != head.Hash {
+		depth = node.HeadNumber - number + 1
+	} else if hash, ok := node.hashes[number-1]; ok && number > 0 && hash != head.ParentHash {
+		depth = node.HeadNumber - number + 2
+	}
+	if depth != 0 {
+		node.Reorgs++
+		if depth > node.MaxReorg {
+			node.MaxReorg = depth
+		}
+		// forget the blocks of the old branch
+		for num := range node.hashes {
+			if num >= number {
+				delete(node.hashes, num)
+			}
+		}
+	}
+
+	node.hashes[number] = head.Hash
+	if number > 0 {
+		node.hashes[number-1] = head.ParentHash
+	}
+	for num := range node.hashes {
+		if num+forkmonHistory < number {
+			delete(node.hashes, num)
+		}
+	}
+
+	node.HeadNumber = number
+	node.HeadHash = head.Hash
+	node.PeerCount = peers
+	node.LastUpdated = time.Now()
+	return nil
+}
+
+func (f *forkmon) handleStatus(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.nodes)
+}
+
+func (f *forkmon) Server(port int) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", f.handleStatus)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(forkmonPage)
+	})
+	return &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Handler: mux,
+	}
+}
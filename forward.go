@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var forwardAddressFlag string
+var forwardPortFlag int
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward <service>:<port>",
+	Short: "Forward a port of a service to another interface",
+	Long:  `Listen on an interface of the host (i.e. 0.0.0.0 to accept remote connections) and forward the TCP connections to a port of a service of the playground running in the output folder. The port is the name of the port in the topology (i.e. reth:http) or its number. It runs until Ctrl+C.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		target, err := resolveForwardTarget(out, args[0])
+		if err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(forwardAddressFlag, strconv.Itoa(forwardPortFlag)))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Forwarding %s to %s (%s)\n", listener.Addr(), target, args[0])
+
+		return forwardConns(cmd.Context(), listener, target)
+	},
+}
+
+// resolveForwardTarget returns the local address of the port of a service in the topology
+func resolveForwardTarget(out *output, arg string) (string, error) {
+	name, portName, ok := strings.Cut(arg, ":")
+	if !ok {
+		return "", fmt.Errorf("invalid target '%s', expected <service>:<port>", arg)
+	}
+	topo, err := loadTopology(out)
+	if err != nil {
+		return "", err
+	}
+	for _, node := range topo.Nodes {
+		if node.Name != name {
+			continue
+		}
+		for _, p := range node.Ports {
+			if p.Name == portName || strconv.Itoa(p.Port) == portName {
+				if p.Protocol == protocolP2P {
+					// the p2p ports also use udp, which cannot be forwarded
+					logger.Warn("Only the tcp connections of the p2p port are forwarded", "service", name, "port", p.Name)
+				}
+				return net.JoinHostPort("127.0.0.1", strconv.Itoa(p.Port)), nil
+			}
+		}
+		return "", fmt.Errorf("service '%s' has no port '%s'", name, portName)
+	}
+	return "", fmt.Errorf("service '%s' not found in the topology", name)
+}
+
+// forwardConns accepts connections until the context is done and pipes each one to the target
+func forwardConns(ctx context.Context, listener net.Listener, target string) error {
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := forwardConn(ctx, conn, target); err != nil {
+				logger.Error("Error forwarding connection", "remote", conn.RemoteAddr().String(), "target", target, "err", err)
+			}
+		}()
+	}
+}
+
+func forwardConn(ctx context.Context, conn net.Conn, target string) error {
+	defer conn.Close()
+
+	var dialer net.Dialer
+	upstream, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	defer upstream.Close()
+
+	// close both sides when the context is done or either side closes
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+	return nil
+}
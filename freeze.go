@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var freezeAtFlag string
+var freezeSnapshotFlag bool
+
+// kinds of freeze conditions
+const (
+	freezeAtBlock = "block"
+	freezeAtSlot  = "slot"
+)
+
+// freezeCondition is the block number of reth or the head slot of the beacon node at
+// which the services are frozen
+type freezeCondition struct {
+	kind  string
+	value uint64
+}
+
+func (f *freezeCondition) String() string {
+	return fmt.Sprintf("%s %d", f.kind, f.value)
+}
+
+// parseFreezeCondition parses the condition of the form block=<number> or slot=<number>
+func parseFreezeCondition(s string) (*freezeCondition, error) {
+	kind, valueStr, ok := strings.Cut(s, "=")
+	if !ok || (kind != freezeAtBlock && kind != freezeAtSlot) {
+		return nil, fmt.Errorf("invalid condition '%s', expected block=<number> or slot=<number>", s)
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s'", kind, valueStr)
+	}
+	return &freezeCondition{kind: kind, value: value}, nil
+}
+
+// reached returns whether the chain is at or past the condition
+func (f *freezeCondition) reached(ctx context.Context) (bool, error) {
+	var (
+		head uint64
+		err  error
+	)
+	if f.kind == freezeAtBlock {
+		head, err = fetchBlockNumber(ctx, "http://localhost:8545")
+	} else {
+		head, err = fetchHeadSlot(ctx, "http://localhost:3500")
+	}
+	if err != nil {
+		return false, err
+	}
+	return head >= f.value, nil
+}
+
+// newFreezeWatcher pauses all the services once the chain reaches the condition and,
+// with --freeze-snapshot, copies the output folder while they are paused
+func newFreezeWatcher(out *output, cond *freezeCondition) func(ctx context.Context) error {
+	frozen := false
+
+	return func(ctx context.Context) error {
+		if frozen {
+			return nil
+		}
+		reached, err := cond.reached(ctx)
+		if err != nil {
+			// the services are not reachable until they start, it is not a failure of the job
+			return nil
+		}
+		if !reached {
+			return nil
+		}
+		frozen = true
+
+		logger.Info("Freezing the services", "at", cond.String())
+		if err := appendEvent(out, fmt.Sprintf("freeze at %s", cond)); err != nil {
+			return err
+		}
+		if err := signalAllServices(out, syscall.SIGSTOP, "paused"); err != nil {
+			return err
+		}
+
+		if freezeSnapshotFlag {
+			dst := fmt.Sprintf("%s-freeze-%s-%d", strings.TrimSuffix(out.dst, string(filepath.Separator)), cond.kind, cond.value)
+			if err := snapshotOutput(out, dst); err != nil {
+				return fmt.Errorf("failed to snapshot the output folder: %w", err)
+			}
+			logger.Info("Snapshot of the output folder, run it with --output <snapshot> --continue", "snapshot", dst)
+		}
+		logger.Info("The services are paused (the in-process ones keep running), run 'resume --all' to resume them")
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/prysmaticlabs/prysm/v5/beacon-chain/state"
+	"github.com/prysmaticlabs/prysm/v5/runtime/version"
+)
+
+var artifactsCacheFlag bool
+
+// genesisCacheDir is the folder under $HOME/.playground with the cached genesis, one
+// folder per hash of the inputs of the genesis
+const genesisCacheDir = "genesis-cache"
+
+// genesisCacheKey is the hash of the inputs of the premined beacon state. The genesis
+// time (and so --genesis-delay and --start-slot) and the EL genesis (i.e. the prefunded
+// balance) are not part of it since the fields that depend on the EL genesis block are
+// rebuilt on the cached state.
+func genesisCacheKey(clConfig string, v int, numValidators uint64) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%d\n", clConfig, version.String(v), numValidators)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func genesisCachePath(key string) (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, genesisCacheDir, key), nil
+}
+
+// loadCachedGenesis reads the cached genesis with the same layout as the output folder,
+// so it is loaded like the genesis of a previous run
+func loadCachedGenesis(key string) (*genesisSnapshot, error) {
+	path, err := genesisCachePath(key)
+	if err != nil {
+		return nil, err
+	}
+	return loadGenesisSnapshot(&output{dst: path})
+}
+
+// storeCachedGenesis writes the genesis state and the validator keystores to the cache.
+// They are written to a temporary folder first so that an interrupted run is not cached.
+func storeCachedGenesis(key string, genesisState state.BeaconState, keystore encObject) error {
+	path, err := genesisCachePath(key)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+
+	tmp := &output{dst: tmpPath}
+	err = tmp.WriteBatch(map[string]interface{}{
+		"testnet/genesis.ssz":            genesisState,
+		validatorKeystoresArtifact + "/": keystore,
+	})
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
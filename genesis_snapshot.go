@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prysmaticlabs/prysm/v5/beacon-chain/core/altair"
+	"github.com/prysmaticlabs/prysm/v5/beacon-chain/state"
+	state_native "github.com/prysmaticlabs/prysm/v5/beacon-chain/state/state-native"
+	fieldparams "github.com/prysmaticlabs/prysm/v5/config/fieldparams"
+	"github.com/prysmaticlabs/prysm/v5/config/params"
+	"github.com/prysmaticlabs/prysm/v5/consensus-types/blocks"
+	"github.com/prysmaticlabs/prysm/v5/consensus-types/interfaces"
+	"github.com/prysmaticlabs/prysm/v5/encoding/bytesutil"
+	enginev1 "github.com/prysmaticlabs/prysm/v5/proto/engine/v1"
+	ethpb "github.com/prysmaticlabs/prysm/v5/proto/prysm/v1alpha1"
+	"github.com/prysmaticlabs/prysm/v5/runtime/version"
+)
+
+// genesisSnapshot holds the artifacts of a previous run that do not depend on the
+// genesis time: the premined beacon state (which only has to be patched with the new time)
+// and the encrypted validator keystores (which are expensive to generate).
+type genesisSnapshot struct {
+	state []byte
+
+	// keystores are the files under data_validator/validators and data_validator/secrets
+	keystores map[string][]byte
+}
+
+// loadGenesisSnapshot reads the genesis artifacts from the output folder of a previous run.
+func loadGenesisSnapshot(out *output) (*genesisSnapshot, error) {
+	stateRaw, err := out.ReadFile(filepath.Join("testnet", "genesis.ssz"))
+	if err != nil {
+		return nil, err
+	}
+
+	keystores := map[string][]byte{}
+	for _, dir := range []string{"validators", "secrets"} {
+		root := filepath.Join(out.dst, validatorKeystoresArtifact)
+		err := filepath.WalkDir(filepath.Join(root, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// only the keystores, not the files created by lighthouse (i.e. slashing protection)
+			if dir == "validators" && d.Name() != "voting-keystore.json" {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			keystores[rel] = data
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &genesisSnapshot{state: stateRaw, keystores: keystores}, nil
+}
+
+// beaconState decodes the snapshot state for the given fork version and validates that
+// it is compatible with the current configuration.
+func (g *genesisSnapshot) beaconState(v int, numValidators int) (state.BeaconState, error) {
+	var (
+		st  state.BeaconState
+		err error
+	)
+	switch v {
+	case version.Deneb:
+		obj := &ethpb.BeaconStateDeneb{}
+		if err := obj.UnmarshalSSZ(g.state); err != nil {
+			return nil, err
+		}
+		st, err = state_native.InitializeFromProtoUnsafeDeneb(obj)
+	case version.Electra:
+		obj := &ethpb.BeaconStateElectra{}
+		if err := obj.UnmarshalSSZ(g.state); err != nil {
+			return nil, err
+		}
+		st, err = state_native.InitializeFromProtoUnsafeElectra(obj)
+	default:
+		return nil, fmt.Errorf("unsupported version %s", version.String(v))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := params.BeaconConfig()
+	forkVersion := config.DenebForkVersion
+	if v == version.Electra {
+		forkVersion = config.ElectraForkVersion
+	}
+	if !bytes.Equal(st.Fork().CurrentVersion, forkVersion) {
+		return nil, fmt.Errorf("fork version mismatch")
+	}
+	if st.NumValidators() != numValidators {
+		return nil, fmt.Errorf("expected %d validators but found %d", numValidators, st.NumValidators())
+	}
+	if g.numKeystores() != numValidators {
+		return nil, fmt.Errorf("expected %d keystores but found %d", numValidators, g.numKeystores())
+	}
+	return st, nil
+}
+
+func (g *genesisSnapshot) numKeystores() int {
+	num := 0
+	for path := range g.keystores {
+		if strings.HasSuffix(path, "voting-keystore.json") {
+			num++
+		}
+	}
+	return num
+}
+
+// Encode implements the encObject interface to write the keystores in the output folder
+func (g *genesisSnapshot) Encode(o *output) error {
+	for path, data := range g.keystores {
+		if err := o.WriteFile(path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchGenesis updates the fields of the premined genesis state that depend on the EL
+// genesis block, which changes with the genesis time and with the prefunded accounts.
+func patchGenesis(st state.BeaconState, genesisTime uint64, v int, block *types.Block) error {
+	blockHash := block.Hash().Bytes()
+
+	if err := st.SetGenesisTime(genesisTime); err != nil {
+		return err
+	}
+
+	mixes := make([][]byte, params.BeaconConfig().EpochsPerHistoricalVector)
+	for i := range mixes {
+		mixes[i] = blockHash
+	}
+	if err := st.SetRandaoMixes(mixes); err != nil {
+		return err
+	}
+
+	eth1Data := st.Eth1Data()
+	eth1Data.BlockHash = blockHash
+	if err := st.SetEth1Data(eth1Data); err != nil {
+		return err
+	}
+
+	header, err := genesisPayloadHeader(v, block)
+	if err != nil {
+		return err
+	}
+	if err := st.SetLatestExecutionPayloadHeader(header); err != nil {
+		return err
+	}
+
+	// the sync committees are computed from the randao mixes
+	syncCommittee, err := altair.NextSyncCommittee(context.Background(), st)
+	if err != nil {
+		return err
+	}
+	if err := st.SetCurrentSyncCommittee(syncCommittee); err != nil {
+		return err
+	}
+	if err := st.SetNextSyncCommittee(syncCommittee); err != nil {
+		return err
+	}
+	return nil
+}
+
+// genesisPayloadHeader returns the execution payload header of the EL genesis block, built
+// as in the premined genesis of prysm so the patched state matches a new one
+func genesisPayloadHeader(v int, block *types.Block) (interfaces.ExecutionData, error) {
+	if block.ExcessBlobGas() == nil || block.BlobGasUsed() == nil {
+		return nil, fmt.Errorf("the EL genesis block has no blob gas fields")
+	}
+	extra := block.Extra()
+	if len(extra) > 32 {
+		extra = extra[:32]
+	}
+	baseFee := bytesutil.PadTo(bytesutil.ReverseByteOrder(block.BaseFee().Bytes()), fieldparams.RootLength)
+
+	switch v {
+	case version.Deneb:
+		payload := &enginev1.ExecutionPayloadDeneb{
+			ParentHash:    block.ParentHash().Bytes(),
+			FeeRecipient:  block.Coinbase().Bytes(),
+			StateRoot:     block.Root().Bytes(),
+			ReceiptsRoot:  block.ReceiptHash().Bytes(),
+			LogsBloom:     block.Bloom().Bytes(),
+			PrevRandao:    params.BeaconConfig().ZeroHash[:],
+			BlockNumber:   block.NumberU64(),
+			GasLimit:      block.GasLimit(),
+			GasUsed:       block.GasUsed(),
+			Timestamp:     block.Time(),
+			ExtraData:     extra,
+			BaseFeePerGas: baseFee,
+			BlockHash:     block.Hash().Bytes(),
+			Transactions:  make([][]byte, 0),
+			Withdrawals:   make([]*enginev1.Withdrawal, 0),
+			ExcessBlobGas: *block.ExcessBlobGas(),
+			BlobGasUsed:   *block.BlobGasUsed(),
+		}
+		wrapped, err := blocks.WrappedExecutionPayloadDeneb(payload)
+		if err != nil {
+			return nil, err
+		}
+		header, err := blocks.PayloadToHeaderDeneb(wrapped)
+		if err != nil {
+			return nil, err
+		}
+		return blocks.WrappedExecutionPayloadHeaderDeneb(header)
+	case version.Electra:
+		payload := &enginev1.ExecutionPayloadElectra{
+			ParentHash:    block.ParentHash().Bytes(),
+			FeeRecipient:  block.Coinbase().Bytes(),
+			StateRoot:     block.Root().Bytes(),
+			ReceiptsRoot:  block.ReceiptHash().Bytes(),
+			LogsBloom:     block.Bloom().Bytes(),
+			PrevRandao:    params.BeaconConfig().ZeroHash[:],
+			BlockNumber:   block.NumberU64(),
+			GasLimit:      block.GasLimit(),
+			GasUsed:       block.GasUsed(),
+			Timestamp:     block.Time(),
+			ExtraData:     extra,
+			BaseFeePerGas: baseFee,
+			BlockHash:     block.Hash().Bytes(),
+			Transactions:  make([][]byte, 0),
+			Withdrawals:   make([]*enginev1.Withdrawal, 0),
+			ExcessBlobGas: *block.ExcessBlobGas(),
+			BlobGasUsed:   *block.BlobGasUsed(),
+		}
+		wrapped, err := blocks.WrappedExecutionPayloadElectra(payload)
+		if err != nil {
+			return nil, err
+		}
+		header, err := blocks.PayloadToHeaderElectra(wrapped)
+		if err != nil {
+			return nil, err
+		}
+		return blocks.WrappedExecutionPayloadHeaderElectra(header)
+	default:
+		return nil, fmt.Errorf("unsupported version %s", version.String(v))
+	}
+}
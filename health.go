@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var healthFollowFlag bool
+var healthIntervalFlag time.Duration
+
+var healthCmd = &cobra.Command{
+	Use:   "health [session]",
+	Short: "Show the health of the running network",
+	Long:  `Show the chain head lag, slot progression, payload delivery status and the health of each service. With a session id (see ls), it fails if the session is not running. The services of every session listen on the same ports, so the running one is checked.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		}
+		var last *healthSummary
+		for {
+			summary := collectHealthSummary(cmd.Context(), last)
+			if healthFollowFlag {
+				// clear the screen before rendering the new summary
+				fmt.Print("\033[H\033[2J")
+			}
+			summary.Print()
+
+			if !healthFollowFlag {
+				return nil
+			}
+			last = summary
+			select {
+			case <-cmd.Context().Done():
+				return nil
+			case <-time.After(healthIntervalFlag):
+			}
+		}
+	},
+}
+
+type serviceHealth struct {
+	name string
+	err  error
+}
+
+type healthSummary struct {
+	time time.Time
+
+	// consensus layer
+	genesisTime  uint64
+	headSlot     uint64
+	expectedSlot uint64
+	slotsPerSec  float64
+
+	// execution layer
+	blockNumber uint64
+
+	// relay
+	payloadsDelivered int
+	lastDeliveredSlot uint64
+
+	services []*serviceHealth
+}
+
+func collectHealthSummary(ctx context.Context, last *healthSummary) *healthSummary {
+	s := &healthSummary{
+		time: time.Now(),
+	}
+
+	check := func(name string, fn func() error) {
+		s.services = append(s.services, &serviceHealth{name: name, err: fn()})
+	}
+
+	check("beacon_node", func() error {
+		clock, err := fetchSlotClock(ctx)
+		if err != nil {
+			return err
+		}
+
+		var syncing struct {
+			Data struct {
+				HeadSlot string `json:"head_slot"`
+			} `json:"data"`
+		}
+		if err := httpGetJSON(ctx, "http://localhost:3500/eth/v1/node/syncing", &syncing); err != nil {
+			return err
+		}
+		headSlot, err := strconv.ParseUint(syncing.Data.HeadSlot, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		s.genesisTime = clock.genesisTime
+		s.headSlot = headSlot
+		s.expectedSlot = clock.Slot(s.time)
+		if last != nil && last.headSlot <= headSlot {
+			s.slotsPerSec = float64(headSlot-last.headSlot) / s.time.Sub(last.time).Seconds()
+		}
+		return nil
+	})
+
+	check("reth", func() error {
+		var blockNumber string
+		if err := rpcCall(ctx, "http://localhost:8545", "eth_blockNumber", nil, &blockNumber); err != nil {
+			return err
+		}
+		num, err := strconv.ParseUint(blockNumber, 0, 64)
+		if err != nil {
+			return err
+		}
+		s.blockNumber = num
+		return nil
+	})
+
+	check("mev-boost-relay", func() error {
+		payloads, err := getProposerPayloadDelivered(ctx)
+		if err != nil {
+			return err
+		}
+		s.payloadsDelivered = len(payloads)
+		for _, payload := range payloads {
+			if payload.Slot > s.lastDeliveredSlot {
+				s.lastDeliveredSlot = payload.Slot
+			}
+		}
+		return nil
+	})
+
+	check("cl-proxy", func() error {
+		return httpGetOK(ctx, "http://localhost:5657/health")
+	})
+
+	check("beacon_node p2p", func() error {
+		return beaconNodeP2PProbe(ctx)
+	})
+
+	check("reth p2p", func() error {
+		return rethP2PProbe()
+	})
+
+	return s
+}
+
+func (s *healthSummary) Print() {
+	fmt.Printf("Network health (%s)\n==================\n", s.time.Format(time.TimeOnly))
+
+	if s.genesisTime != 0 {
+		var lag uint64
+		if s.expectedSlot > s.headSlot {
+			lag = s.expectedSlot - s.headSlot
+		}
+		fmt.Printf("Head slot: %d (expected %d, lag %d slots)\n", s.headSlot, s.expectedSlot, lag)
+		fmt.Printf("Slot progression: %.2f slots/s\n", s.slotsPerSec)
+	}
+	fmt.Printf("EL block number: %d\n", s.blockNumber)
+	fmt.Printf("Payloads delivered: %d (last slot %d)\n", s.payloadsDelivered, s.lastDeliveredSlot)
+
+	fmt.Printf("\nServices:\n")
+	for _, svc := range s.services {
+		if svc.err != nil {
+			fmt.Printf("- %s: unhealthy (%v)\n", svc.name, svc.err)
+		} else {
+			fmt.Printf("- %s: healthy\n", svc.name)
+		}
+	}
+}
+
+var healthClient = &http.Client{Timeout: 2 * time.Second}
+
+func httpGetOK(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status code %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return nil
+}
+
+func httpGetJSON(ctx context.Context, url string, obj interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(obj)
+}
+
+func rpcCall(ctx context.Context, url string, method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return err
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("rpc error: %s", rpcResp.Error.Message)
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var hostsNameFlag string
+
+var hostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Print a hosts file block with a hostname for each service",
+	Long:  `Print a block to append to /etc/hosts that resolves a <service>.playground.local hostname for each service to 127.0.0.1, and the endpoints of the services with these hostnames`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		endpoints, err := loadEndpoints(newOutput(outputFlag))
+		if err != nil {
+			return err
+		}
+		fmt.Print(hostsBlock(endpoints, hostsNameFlag))
+		return nil
+	},
+}
+
+// loadEndpoints reads the endpoints of each service written in endpoints.json at startup
+func loadEndpoints(out *output) (map[string]map[string]string, error) {
+	data, err := out.ReadFile("endpoints.json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("endpoints.json not found in %s, is the playground running?", out.dst)
+		}
+		return nil, err
+	}
+	var endpoints map[string]map[string]string
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// serviceHostname returns the hostname of the service. The underscores are not valid in hostnames.
+func serviceHostname(service string, name string) string {
+	host := strings.ReplaceAll(service, "_", "-")
+	if name != "" {
+		host += "." + name
+	}
+	return host + ".playground.local"
+}
+
+func hostsBlock(endpoints map[string]map[string]string, name string) string {
+	services := []string{}
+	for service := range endpoints {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	var b strings.Builder
+	b.WriteString("# builder-playground\n")
+	for _, service := range services {
+		fmt.Fprintf(&b, "127.0.0.1 %s\n", serviceHostname(service, name))
+	}
+	b.WriteString("# end builder-playground\n\n")
+
+	b.WriteString("# Endpoints:\n")
+	for _, service := range services {
+		ports := []string{}
+		for port := range endpoints[service] {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+
+		for _, port := range ports {
+			endpoint := endpoints[service][port]
+			if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+				u.Host = serviceHostname(service, name) + ":" + u.Port()
+				endpoint = u.String()
+			} else {
+				// host:port endpoints (i.e. p2p)
+				endpoint = strings.Replace(endpoint, "localhost", serviceHostname(service, name), 1)
+			}
+			fmt.Fprintf(&b, "# %s %s: %s\n", service, port, endpoint)
+		}
+	}
+	return b.String()
+}
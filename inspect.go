@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var inspectResolvedFlag bool
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <session> [service]",
+	Short: "Show the ports and the connections of the services of a session",
+	Long:  `Show the ports of each service of the session with the given id (see ls), or only the given service, and the ports of the other services it connects to. With --resolved, it also shows the command line and the environment variables of each service as the playground ran them, after the templates, the overrides and the limits are applied.`,
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveSessionOutput(args[0]); err != nil {
+			return err
+		}
+		topo, err := loadTopology(&output{dst: outputFlag})
+		if err != nil {
+			return err
+		}
+
+		nodes := topo.Nodes
+		if len(args) == 2 {
+			node := topo.node(args[1])
+			if node == nil {
+				return fmt.Errorf("service '%s' not found in the session", args[1])
+			}
+			nodes = []*topologyNode{node}
+		}
+		for i, node := range nodes {
+			if i != 0 {
+				fmt.Println()
+			}
+			printInspect(topo, node)
+		}
+		return nil
+	},
+}
+
+func printInspect(topo *topology, node *topologyNode) {
+	kind := "process"
+	if node.InProcess {
+		kind = "in the playground process"
+	} else if node.Job {
+		kind = "job"
+	}
+	fmt.Printf("%s (%s)\n", node.Name, kind)
+
+	if inspectResolvedFlag && len(node.Command) != 0 {
+		fmt.Printf("  command: %s\n", strings.Join(node.Command, " "))
+		if len(node.HostEnv) == 0 {
+			fmt.Println("  env: inherits the environment of the playground")
+		} else {
+			fmt.Printf("  env: %s from the playground\n", strings.Join(node.HostEnv, ", "))
+		}
+		for _, env := range node.Env {
+			fmt.Printf("    %s\n", env)
+		}
+	}
+
+	if len(node.Ports) != 0 {
+		fmt.Println("  ports:")
+		for _, p := range node.Ports {
+			fmt.Printf("    %s: %d (%s)\n", p.Name, p.Port, p.URL)
+		}
+	}
+
+	conns := []string{}
+	for _, edge := range topo.Edges {
+		if edge.From != node.Name {
+			continue
+		}
+		target := fmt.Sprintf("%s %s", edge.To, edge.Port)
+		if to := topo.node(edge.To); to != nil {
+			for _, p := range to.Ports {
+				if p.Name == edge.Port {
+					target += fmt.Sprintf(" (%s)", p.URL)
+				}
+			}
+		}
+		conns = append(conns, fmt.Sprintf("    %s: %s", edge.Type, target))
+	}
+	if len(conns) != 0 {
+		fmt.Println("  connects to:")
+		fmt.Println(strings.Join(conns, "\n"))
+	}
+}
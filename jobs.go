@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// jobArtifact is written in the output folder when the job completes. The services that
+// need the job (i.e. a contract deployed at genesis) wait for it with DependsOnArtifact.
+func jobArtifact(name string) string {
+	return filepath.Join("jobs", name)
+}
+
+// AsJob marks the service as a one-shot job. The job must exit with 0 before the timeout,
+// otherwise the playground stops. Unlike the other services, its exit does not stop the
+// playground when it succeeds.
+func (s *service) AsJob(timeout time.Duration) *service {
+	s.job = true
+	s.jobTimeout = timeout
+	return s
+}
+
+// watchJob kills the job if it is still running after its timeout
+func (s *serviceManager) watchJob(h *handle) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		select {
+		case <-h.doneCh:
+		case <-s.ctx.Done():
+		case <-time.After(h.Service.jobTimeout):
+			logger.Error("Job timed out", "job", h.Service.name, "timeout", h.Service.jobTimeout)
+			h.signal(syscall.SIGKILL)
+		}
+	}()
+}
+
+// completeJob records that the job exited with 0
+func (s *serviceManager) completeJob(ss *service) {
+	if err := s.out.WriteFile(jobArtifact(ss.name), time.Now().Format(time.RFC3339)); err != nil {
+		logger.Error("Error writing the artifact of the job", "job", ss.name, "err", err)
+		s.emitError()
+		return
+	}
+	if err := appendEvent(s.out, "job "+ss.name+" completed"); err != nil {
+		logger.Error("Error writing the event of the job", "job", ss.name, "err", err)
+	}
+	logger.Info("Job completed", "job", ss.name)
+	emitProgress(&progressEvent{Type: progressJobCompleted, Service: ss.name})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/hex"
+	"path/filepath"
+
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// jwtConnBeaconNode is the engine api connection between the beacon node and the cl-proxy.
+// The other connections are named after the target of the cl-proxy (reth, the followers
+// and the secondary builder).
+const (
+	jwtConnBeaconNode = "beacon_node"
+	jwtConnSecondary  = "secondary"
+)
+
+// engineJWTArtifact returns the jwt secret of the engine api connection. Without the
+// split-jwt feature all the connections share the same secret.
+func engineJWTArtifact(conn string) string {
+	if !features.Enabled(featureSplitJWT) {
+		return jwtSecretArtifact
+	}
+	return filepath.Join("jwt", conn)
+}
+
+// EngineJWTSecret returns the jwt secret of the engine api connection. It is derived from
+// the jwt secret of the registry so that it is stable across restarts.
+func (k *keyRegistry) EngineJWTSecret(conn string) string {
+	if !features.Enabled(featureSplitJWT) {
+		return k.jwtSecret
+	}
+	return hex.EncodeToString(ecrypto.Keccak256([]byte(k.jwtSecret), []byte(conn)))
+}
+
+// engineJWTConns returns the engine api connections of the playground
+func engineJWTConns() []string {
+	conns := []string{jwtConnBeaconNode, "reth"}
+	for _, f := range rethFollowers() {
+		conns = append(conns, f.name)
+	}
+	if secondaryBuilderPort != 0 {
+		conns = append(conns, jwtConnSecondary)
+	}
+	return conns
+}
+
+// engineJWTArtifacts returns the files with the jwt secret of each connection
+func engineJWTArtifacts(keys *keyRegistry) map[string]interface{} {
+	artifacts := map[string]interface{}{}
+	if !features.Enabled(featureSplitJWT) {
+		return artifacts
+	}
+	for _, conn := range engineJWTConns() {
+		artifacts[engineJWTArtifact(conn)] = keys.EngineJWTSecret(conn)
+	}
+	return artifacts
+}
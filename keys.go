@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/prysmaticlabs/prysm/v5/crypto/bls"
+)
+
+var (
+	defaultJWTToken             = "04592280e1778419b7aa954d43871cb2cfb2ebda754fb735e8adeb293a88f9bf"
+	defaultRethDiscoveryPrivKey = "a11ac89899cd86e36b6fb881ec1255b8a92a688790b7d950f8b7d8dd626671fb"
+	defaultRelaySecretKey       = "5eae315483f028b5cdd5d1090ff0c7618b18737ea9bf3c35047189db22835c48"
+)
+
+// location of each of the keys inside the output folder
+const (
+	jwtSecretArtifact   = "jwtsecret"
+	rethP2PKeyArtifact  = "keys/reth_p2p"
+	relaySecretArtifact = "keys/relay"
+)
+
+// keyRegistry holds the private keys used by the components of the playground.
+// The keys are written as artifacts in the output folder so that they can be
+// reused when the chain is restarted with --continue.
+type keyRegistry struct {
+	jwtSecret      string
+	rethP2PKey     string
+	relaySecretKey string
+}
+
+// defaultKeyRegistry returns the registry with the well-known keys. It is useful
+// to have a deterministic setup (i.e. the enode address of reth is always the same).
+func defaultKeyRegistry() *keyRegistry {
+	return &keyRegistry{
+		jwtSecret:      defaultJWTToken,
+		rethP2PKey:     defaultRethDiscoveryPrivKey,
+		relaySecretKey: defaultRelaySecretKey,
+	}
+}
+
+// newRandomKeyRegistry generates a new set of keys unique for this session.
+func newRandomKeyRegistry() (*keyRegistry, error) {
+	jwtSecret := make([]byte, 32)
+	if _, err := rand.Read(jwtSecret); err != nil {
+		return nil, err
+	}
+
+	rethP2PKey, err := ecrypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	relaySecretKey, err := bls.RandKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyRegistry{
+		jwtSecret:      hex.EncodeToString(jwtSecret),
+		rethP2PKey:     hex.EncodeToString(ecrypto.FromECDSA(rethP2PKey)),
+		relaySecretKey: hex.EncodeToString(relaySecretKey.Marshal()),
+	}, nil
+}
+
+// loadKeyRegistry reads the keys written by a previous session in the output folder.
+// If a key is not found (i.e. the output folder was created by an older version)
+// it falls back to the default one.
+func loadKeyRegistry(out *output) (*keyRegistry, error) {
+	keys := defaultKeyRegistry()
+
+	for path, dst := range map[string]*string{
+		jwtSecretArtifact:   &keys.jwtSecret,
+		rethP2PKeyArtifact:  &keys.rethP2PKey,
+		relaySecretArtifact: &keys.relaySecretKey,
+	} {
+		data, err := out.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+		}
+		*dst = strings.TrimSpace(string(data))
+	}
+	return keys, nil
+}
+
+func (k *keyRegistry) JWTSecret() string {
+	return k.jwtSecret
+}
+
+func (k *keyRegistry) RethP2PKey() string {
+	return k.rethP2PKey
+}
+
+func (k *keyRegistry) RelaySecretKey() string {
+	return k.relaySecretKey
+}
+
+// Artifacts returns the files to write in the output folder for the keys
+func (k *keyRegistry) Artifacts() map[string]interface{} {
+	return map[string]interface{}{
+		jwtSecretArtifact:   k.jwtSecret,
+		rethP2PKeyArtifact:  k.rethP2PKey,
+		relaySecretArtifact: k.relaySecretKey,
+	}
+}
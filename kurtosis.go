@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/prysmaticlabs/prysm/v5/config/params"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// kurtosisParamsArtifact is the configuration of the chain as network params of the
+// kurtosis ethereum-package
+const kurtosisParamsArtifact = "kurtosis/network_params.yaml"
+
+// container images of the clients in the ethereum-package
+const (
+	kurtosisRethImage       = "ghcr.io/paradigmxyz/reth"
+	kurtosisLighthouseImage = "sigp/lighthouse"
+	kurtosisPrysmImage      = "gcr.io/prysmaticlabs/prysm/beacon-chain"
+	kurtosisPrysmVCImage    = "gcr.io/prysmaticlabs/prysm/validator"
+)
+
+// kurtosisCLVersionFlags are the version flags of each cl_type
+var kurtosisCLVersionFlags = map[string]string{
+	clLighthouse: "--lighthouse-version",
+	clPrysm:      "--prysm-version",
+}
+
+type kurtosisParticipant struct {
+	ELType  string `yaml:"el_type"`
+	ELImage string `yaml:"el_image,omitempty"`
+	CLType  string `yaml:"cl_type"`
+	CLImage string `yaml:"cl_image,omitempty"`
+	VCImage string `yaml:"vc_image,omitempty"`
+	Count   int    `yaml:"count,omitempty"`
+}
+
+type kurtosisNetworkParams struct {
+	Network                string  `yaml:"network,omitempty"`
+	NetworkID              string  `yaml:"network_id,omitempty"`
+	DepositContractAddress string  `yaml:"deposit_contract_address,omitempty"`
+	SecondsPerSlot         uint64  `yaml:"seconds_per_slot,omitempty"`
+	NumValidatorKeys       uint64  `yaml:"num_validator_keys_per_node,omitempty"`
+	GenesisDelay           uint64  `yaml:"genesis_delay,omitempty"`
+	Preset                 string  `yaml:"preset,omitempty"`
+	ElectraForkEpoch       *uint64 `yaml:"electra_fork_epoch,omitempty"`
+	PrefundedAccounts      string  `yaml:"prefunded_accounts,omitempty"`
+}
+
+// kurtosisParams is the subset of the network_params.yaml file of the ethereum-package
+// (https://github.com/ethpandaops/ethereum-package) that maps to the playground
+type kurtosisParams struct {
+	Participants  []*kurtosisParticipant `yaml:"participants"`
+	NetworkParams kurtosisNetworkParams  `yaml:"network_params"`
+	MevType       string                 `yaml:"mev_type,omitempty"`
+}
+
+// newKurtosisParams describes the chain generated by the playground. The validator keys
+// are not included, the ethereum-package derives them from a mnemonic.
+func newKurtosisParams(config *params.BeaconChainConfig, numValidators uint64) (*kurtosisParams, error) {
+	participant := &kurtosisParticipant{ELType: "reth", CLType: clClientFlag, Count: 1}
+	if rethVersionFlag != "" {
+		participant.ELImage = kurtosisRethImage + ":" + rethVersionFlag
+	}
+	if clClientFlag == clLighthouse && lighthouseVersionFlag != "" {
+		participant.CLImage = kurtosisLighthouseImage + ":" + lighthouseVersionFlag
+	}
+	if clClientFlag == clPrysm && prysmVersionFlag != "" {
+		participant.CLImage = kurtosisPrysmImage + ":" + prysmVersionFlag
+		participant.VCImage = kurtosisPrysmVCImage + ":" + prysmVersionFlag
+	}
+
+	prefunded, err := kurtosisPrefundedAccounts()
+	if err != nil {
+		return nil, err
+	}
+	electraForkEpoch := uint64(config.ElectraForkEpoch)
+
+	return &kurtosisParams{
+		Participants: []*kurtosisParticipant{participant},
+		NetworkParams: kurtosisNetworkParams{
+			NetworkID:              strconv.FormatUint(config.DepositChainID, 10),
+			DepositContractAddress: config.DepositContractAddress,
+			SecondsPerSlot:         config.SecondsPerSlot,
+			NumValidatorKeys:       numValidators,
+			GenesisDelay:           genesisDelayFlag,
+			Preset:                 config.PresetBase,
+			ElectraForkEpoch:       &electraForkEpoch,
+			PrefundedAccounts:      prefunded,
+		},
+		MevType: "flashbots",
+	}, nil
+}
+
+// kurtosisPrefundedAccounts returns the prefunded accounts of the playground in the
+// format of the ethereum-package (a json object with the balance of each address)
+func kurtosisPrefundedAccounts() (string, error) {
+	// the balance of the prefunded accounts in ETH
+	balance := prefundedBalance()
+	balance.Div(balance, big.NewInt(1e18))
+
+	prefunded := map[string]map[string]string{}
+	for _, privStr := range prefundedAccounts {
+		priv, err := getPrivKey(privStr)
+		if err != nil {
+			return "", err
+		}
+		addr := ecrypto.PubkeyToAddress(priv.PublicKey)
+		prefunded[addr.Hex()] = map[string]string{"balance": balance.String() + "ETH"}
+	}
+	data, err := json.Marshal(prefunded)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// imageVersion returns the tag of a container image
+func imageVersion(image string) string {
+	if idx := strings.LastIndex(image, ":"); idx != -1 && !strings.Contains(image[idx:], "/") {
+		return image[idx+1:]
+	}
+	return ""
+}
+
+// playgroundArgs converts the network params of the ethereum-package into the flags of
+// the playground. The settings without an equivalent are returned as warnings.
+func (k *kurtosisParams) playgroundArgs() ([]string, []string, error) {
+	args := []string{}
+	warnings := []string{}
+
+	if len(k.Participants) != 1 || k.Participants[0].Count > 1 {
+		return nil, nil, fmt.Errorf("the playground runs a single node, found %d participants", len(k.Participants))
+	}
+	participant := k.Participants[0]
+	if participant.ELType != "" && participant.ELType != "reth" {
+		return nil, nil, fmt.Errorf("unsupported el_type '%s', the playground only runs reth", participant.ELType)
+	}
+	clType := participant.CLType
+	if clType == "" {
+		clType = clLighthouse
+	}
+	clVersionFlag, ok := kurtosisCLVersionFlags[clType]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported cl_type '%s', the playground only runs lighthouse or prysm", clType)
+	}
+	if clType != clLighthouse {
+		args = append(args, "--cl-client", clType)
+	}
+	for _, client := range []struct{ flag, image string }{
+		{"--reth-version", participant.ELImage},
+		{clVersionFlag, participant.CLImage},
+	} {
+		if client.image == "" {
+			continue
+		}
+		if version := imageVersion(client.image); strings.HasPrefix(version, "v") {
+			args = append(args, client.flag, version)
+		} else {
+			warnings = append(warnings, fmt.Sprintf("image %s is not a release, using the default version", client.image))
+		}
+	}
+
+	network := k.NetworkParams
+	if network.Network != "" && network.Network != "kurtosis" {
+		if _, ok := checkpointSyncURLs[network.Network]; !ok {
+			return nil, nil, fmt.Errorf("unsupported network '%s'", network.Network)
+		}
+		args = append(args, "--network", network.Network)
+	}
+	if network.SecondsPerSlot != 0 && network.SecondsPerSlot != 12 {
+		return nil, nil, fmt.Errorf("the playground only supports 12 seconds slots, found %d", network.SecondsPerSlot)
+	}
+	if network.Preset != "" && network.Preset != "mainnet" {
+		return nil, nil, fmt.Errorf("the playground only supports the mainnet preset, found '%s'", network.Preset)
+	}
+	if network.GenesisDelay != 0 {
+		if network.GenesisDelay < minimumGenesisDelay {
+			warnings = append(warnings, fmt.Sprintf("genesis delay %d is below the minimum, using %d", network.GenesisDelay, minimumGenesisDelay))
+		} else {
+			args = append(args, "--genesis-delay", strconv.FormatUint(network.GenesisDelay, 10))
+		}
+	}
+	if epoch := network.ElectraForkEpoch; epoch != nil {
+		if *epoch == 0 {
+			args = append(args, "--electra")
+		} else if *epoch != math.MaxUint64 {
+			return nil, nil, fmt.Errorf("the playground only enables electra at genesis, found epoch %d", *epoch)
+		}
+	}
+	if network.NetworkID != "" && network.NetworkID != "1337" {
+		warnings = append(warnings, fmt.Sprintf("network id %s is ignored, the playground uses 1337", network.NetworkID))
+	}
+	if network.NumValidatorKeys != 0 && network.NumValidatorKeys != defaultNumValidators {
+		args = append(args, "--num-validators", strconv.FormatUint(network.NumValidatorKeys, 10))
+	}
+	if prefunded, _ := kurtosisPrefundedAccounts(); network.PrefundedAccounts != "" && network.PrefundedAccounts != prefunded {
+		warnings = append(warnings, "prefunded accounts are ignored, the playground uses the well-known accounts")
+	}
+	if k.MevType != "" && k.MevType != "flashbots" {
+		warnings = append(warnings, fmt.Sprintf("mev type '%s' is ignored, the playground runs the flashbots relay", k.MevType))
+	}
+	return args, warnings, nil
+}
+
+var importKurtosisCmd = &cobra.Command{
+	Use:   "import-kurtosis <network_params.yaml>",
+	Short: "Print the playground flags equivalent to a kurtosis network_params.yaml",
+	Long:  `Convert the network params of the kurtosis ethereum-package into the flags of the playground. The settings that the playground does not support are reported as warnings`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		var k kurtosisParams
+		if err := yaml.Unmarshal(data, &k); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", args[0], err)
+		}
+
+		flags, warnings, err := k.playgroundArgs()
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+		fmt.Println(strings.TrimSpace("playground " + strings.Join(flags, " ")))
+		return nil
+	},
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// outputLayoutVersion is the version of the layout of the output folder. It must be
+// increased (with a migration) whenever a file of the output folder is moved or its
+// format changes.
+const outputLayoutVersion = 1
+
+// layoutArtifact describes the layout of the output folder for external tools
+const layoutArtifact = "layout.json"
+
+type outputLayout struct {
+	Version int `json:"version"`
+
+	// Paths are the locations of the artifacts inside the output folder
+	Paths map[string]string `json:"paths"`
+}
+
+func newOutputLayout() *outputLayout {
+	return &outputLayout{
+		Version: outputLayoutVersion,
+		Paths: map[string]string{
+			"logs":               "logs",
+			"pids":               "pids",
+			"keys":               "keys",
+			"jwt_secret":         jwtSecretArtifact,
+			"jwt":                "jwt",
+			"genesis":            "genesis.json",
+			"testnet":            "testnet",
+			"endpoints":          "endpoints.json",
+			"output":             outputSummaryArtifact,
+			"topology":           "topology.json",
+			"events":             "events.log",
+			"rpc":                rpcRecordingArtifact,
+			"engine":             engineRecordingArtifact,
+			"kurtosis":           kurtosisParamsArtifact,
+			"readiness":          "readiness",
+			"jobs":               "jobs",
+			"versions":           "versions",
+			"pid":                playgroundPidArtifact,
+			"session":            sessionArtifact,
+			"relay_api_stats":    relayAPIStatsArtifact,
+			"relay_api_requests": relayRequestLogArtifact,
+			"relay_loadgen":      relayLoadgenStatsArtifact,
+			"relay_validation":   relayValidationStatsArtifact,
+			"relay_winning_bids": relayWinningBidsArtifact,
+		},
+	}
+}
+
+// migrations upgrade the output folder from the version of their index to the next one
+var migrations = []func(out *output) error{
+	// v0 -> v1: the keys are stored in the output folder. The folders created before
+	// always used the well-known keys.
+	func(out *output) error {
+		keys, err := loadKeyRegistry(out)
+		if err != nil {
+			return err
+		}
+		return out.WriteBatch(keys.Artifacts())
+	},
+}
+
+// readLayoutVersion returns the layout version of the output folder. The folders
+// created before the layout was versioned do not have a layout file and are version 0.
+func readLayoutVersion(out *output) (int, error) {
+	data, err := out.ReadFile(layoutArtifact)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var layout outputLayout
+	if err := json.Unmarshal(data, &layout); err != nil {
+		return 0, fmt.Errorf("failed to decode %s: %w", layoutArtifact, err)
+	}
+	return layout.Version, nil
+}
+
+// migrateOutput upgrades the output folder to the current layout version
+func migrateOutput(out *output) error {
+	version, err := readLayoutVersion(out)
+	if err != nil {
+		return err
+	}
+	if version > outputLayoutVersion {
+		return fmt.Errorf("output folder layout version %d is newer than the supported one (%d), upgrade the playground", version, outputLayoutVersion)
+	}
+	if version == outputLayoutVersion {
+		return nil
+	}
+
+	for v := version; v < outputLayoutVersion; v++ {
+		logger.Info("Migrating output folder layout", "from", v, "to", v+1)
+		if err := migrations[v](out); err != nil {
+			return fmt.Errorf("failed to migrate output folder to version %d: %w", v+1, err)
+		}
+	}
+	return out.WriteFile(layoutArtifact, newOutputLayout())
+}
+
+var migrateOutputCmd = &cobra.Command{
+	Use:   "migrate-output",
+	Short: "Upgrade the output folder of a previous version of the playground",
+	Long:  `Upgrade the layout of an output folder created by a previous version of the playground to the current one, so that the chain can be continued and the tools that read the folder find the files`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+		if !out.Exists("") {
+			return fmt.Errorf("output folder %s does not exist", out.dst)
+		}
+
+		version, err := readLayoutVersion(out)
+		if err != nil {
+			return err
+		}
+		if version == outputLayoutVersion {
+			fmt.Printf("Output folder is already at layout version %d\n", version)
+			return nil
+		}
+		if err := migrateOutput(out); err != nil {
+			return err
+		}
+		fmt.Printf("Output folder migrated to layout version %d\n", outputLayoutVersion)
+		return nil
+	},
+}
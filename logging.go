@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// formats of the log of the playground
+const (
+	logText = "text"
+	logJSON = "json"
+)
+
+var logFormatFlag string
+var logLevelFlag string
+
+// logger is the log of the playground itself (the services write to their own logs). It
+// goes to stderr so that stdout only has the summary of the playground.
+var logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// setupLogging configures the logger with --log-format and --log-level
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevelFlag)); err != nil {
+		return fmt.Errorf("unknown --log-level '%s', it must be debug, info, warn or error", logLevelFlag)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch logFormatFlag {
+	case logText:
+		logger = slog.New(slog.NewTextHandler(os.Stderr, opts))
+	case logJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	default:
+		return fmt.Errorf("unknown --log-format '%s', it must be %s or %s", logFormatFlag, logText, logJSON)
+	}
+	// the log package of the dependencies goes through the logger too
+	slog.SetDefault(logger)
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var lsJSONFlag bool
+
+// sessionSummary is a running session listed by the ls command
+type sessionSummary struct {
+	ID       string    `json:"id"`
+	Chain    string    `json:"chain"`
+	CLClient string    `json:"cl_client"`
+	Started  time.Time `json:"started"`
+	Output   string    `json:"output"`
+	Services int       `json:"services"`
+
+	// Health is ok if all the services are running (or the jobs completed), otherwise
+	// the services that are not
+	Health string `json:"health"`
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the running playgrounds",
+	Long:  `List the playgrounds running in this machine with their chain, start time, output folder, number of services and a summary of the services that are not running`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessions, err := listSessions()
+		if err != nil {
+			return err
+		}
+
+		if lsJSONFlag {
+			data, err := json.MarshalIndent(sessions, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(sessions) == 0 {
+			fmt.Println("No playgrounds running")
+			return nil
+		}
+		for _, s := range sessions {
+			chain := s.Chain
+			if s.CLClient != "" {
+				chain += ", " + s.CLClient
+			}
+			fmt.Printf("- %s (%s)\n", s.ID, chain)
+			fmt.Printf("    started: %s (up %s)\n", s.Started.Format(time.DateTime), formatUptime(s.Started))
+			fmt.Printf("    output: %s\n", s.Output)
+			fmt.Printf("    services: %d, %s\n", s.Services, s.Health)
+		}
+		return nil
+	},
+}
+
+// sessionsDir has a file for each running session with the path of its output folder
+func sessionsDir() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "sessions"), nil
+}
+
+// registerSession records the output folder of the session so that ls can find it
+func registerSession(id string, out *output) error {
+	dir, err := sessionsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dst, err := filepath.Abs(out.dst)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, id), []byte(dst), 0644)
+}
+
+func unregisterSession(id string) {
+	if dir, err := sessionsDir(); err == nil {
+		os.Remove(filepath.Join(dir, id))
+	}
+}
+
+// listSessions returns the running sessions sorted by start time. The sessions that are
+// not running anymore (i.e. the playground was killed) are removed from the registry.
+func listSessions() ([]*sessionSummary, error) {
+	dir, err := sessionsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sessions := []*sessionSummary{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		dst, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		out := &output{dst: string(dst)}
+
+		var info sessionInfo
+		data, err := out.ReadFile(sessionArtifact)
+		if err == nil {
+			err = json.Unmarshal(data, &info)
+		}
+		if _, running := runningSession(out); err != nil || !running || info.ID != entry.Name() {
+			os.Remove(path)
+			continue
+		}
+
+		summary := &sessionSummary{
+			ID:       info.ID,
+			Chain:    info.Chain,
+			CLClient: info.CLClient,
+			Started:  info.Started,
+			Output:   out.dst,
+			Health:   "unknown",
+		}
+		if statuses, err := collectStatus(out); err == nil {
+			summary.Services = len(statuses)
+			summary.Health = sessionHealth(statuses)
+		}
+		sessions = append(sessions, summary)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Started.Before(sessions[j].Started)
+	})
+	return sessions, nil
+}
+
+// sessionHealth summarizes the services that are not running
+func sessionHealth(statuses []*serviceStatus) string {
+	unhealthy := []string{}
+	for _, s := range statuses {
+		if s.State != serviceRunning && s.State != jobCompleted {
+			unhealthy = append(unhealthy, s.Name+" "+s.State)
+		}
+	}
+	if len(unhealthy) == 0 {
+		return "ok"
+	}
+	return strings.Join(unhealthy, ", ")
+}
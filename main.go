@@ -10,6 +10,7 @@ import (
 	"html/template"
 	"io"
 	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -18,9 +19,11 @@ import (
 	"reflect"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/flashbots/mev-boost-relay/beaconclient"
@@ -33,13 +36,16 @@ import (
 	"github.com/ferranbt/builder-playground/artifacts"
 	clproxy "github.com/ferranbt/builder-playground/cl-proxy"
 	mevboostrelay "github.com/ferranbt/builder-playground/mev-boost-relay"
+	relayloadgen "github.com/ferranbt/builder-playground/relay-loadgen"
 
 	"github.com/hashicorp/go-uuid"
+	"github.com/prysmaticlabs/prysm/v5/beacon-chain/state"
 	"github.com/prysmaticlabs/prysm/v5/config/params"
 	"github.com/prysmaticlabs/prysm/v5/crypto/bls/common"
 	"github.com/prysmaticlabs/prysm/v5/runtime/interop"
 	"github.com/prysmaticlabs/prysm/v5/runtime/version"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	keystorev4 "github.com/wealdtech/go-eth2-wallet-encryptor-keystorev4"
 	"gopkg.in/yaml.v2"
 )
@@ -47,28 +53,67 @@ import (
 //go:embed config.yaml.tmpl
 var clConfigContent []byte
 
-var defaultJWTToken = "04592280e1778419b7aa954d43871cb2cfb2ebda754fb735e8adeb293a88f9bf"
-
-var (
-	defaultRethDiscoveryPrivKey    = "a11ac89899cd86e36b6fb881ec1255b8a92a688790b7d950f8b7d8dd626671fb"
-	defaultRethDiscoveryPrivKeyLoc = "/tmp/tmp-reth-disc.txt"
-)
-
 var outputFlag string
 var continueFlag bool
 var useBinPathFlag bool
 var validateFlag bool
 var genesisDelayFlag uint64
+var startSlotFlag uint64
+
+// maxStartSlot is the highest --start-slot (8 epochs), since the beacon node processes the
+// empty slots between the genesis and the start slot when it starts
+const maxStartSlot = 256
+
 var latestForkFlag bool
 var useRethForValidation bool
+var offlineFlag bool
+var downloadRetriesFlag int
+var downloadMirrorsFlag []string
+var validationServerAddrFlag string
 var secondaryBuilderPort uint64
+var clProxyCompareFlag bool
+var uniqueKeysFlag bool
+var networkFlag string
+var checkpointSyncURLFlag string
+var uploadArtifactsFlag string
+var uploadArtifactsRetentionFlag string
+var stopGracePeriodFlag time.Duration
+var envPassthroughFlag []string
+var noDegradeFlag bool
+var withForkmonFlag bool
+var rethVersionFlag string
+var lighthouseVersionFlag string
+var relayRequestLogFlag bool
+var relayLoadgenRateFlag float64
+var relayLoadgenValueFlag string
+
+// artifacts with the latency stats and the requests of the relay api
+const (
+	relayAPIStatsArtifact   = "relay_api_stats.json"
+	relayRequestLogArtifact = "relay_api_requests.jsonl"
+)
+
+// relayValidationStatsArtifact has the results of the validation of the builder submissions
+const relayValidationStatsArtifact = "relay_validation_stats.json"
+
+// relayWinningBidsArtifact has the winning bid of each slot and the number of competing bids
+const relayWinningBidsArtifact = "relay_winning_bids.json"
+
+// relayLoadgenStatsArtifact has the results of the submissions of the relay load generator
+const relayLoadgenStatsArtifact = "relay_loadgen_stats.json"
 
 var rootCmd = &cobra.Command{
 	Use:   "playground",
 	Short: "",
 	Long:  ``,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runIt()
+		sessionFlagsHash = flagsHash(cmd.Flags())
+		if reuseFlag {
+			if reused, err := reuseSession(); err != nil || reused {
+				return err
+			}
+		}
+		return runIt(cmd.Context())
 	},
 }
 
@@ -77,7 +122,7 @@ var downloadArtifactsCmd = &cobra.Command{
 	Short: "Download the artifacts",
 	Long:  `Download the artifacts`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		bins, err := artifacts.DownloadArtifacts()
+		bins, err := artifacts.DownloadArtifacts(releaseNames(), releaseVersions(), downloadConfig())
 		if err != nil {
 			return err
 		}
@@ -85,7 +130,7 @@ var downloadArtifactsCmd = &cobra.Command{
 		if validateFlag {
 			for _, path := range bins {
 				// make sure you can run the binary
-				// In this case, both reth and lighthouse have the --version flag
+				// In this case, all the binaries have the --version flag
 				cmd := exec.Command(path, "--version")
 				if err := cmd.Run(); err != nil {
 					return fmt.Errorf("error running %s: %v", path, err)
@@ -188,93 +233,222 @@ var watchCmd = &cobra.Command{
 var minimumGenesisDelay uint64 = 10
 
 func main() {
-	rootCmd.Flags().StringVar(&outputFlag, "output", "", "")
-	rootCmd.Flags().BoolVar(&continueFlag, "continue", false, "")
-	rootCmd.Flags().BoolVar(&useBinPathFlag, "use-bin-path", false, "")
-	rootCmd.Flags().Uint64Var(&genesisDelayFlag, "genesis-delay", minimumGenesisDelay, "")
-	rootCmd.Flags().BoolVar(&latestForkFlag, "electra", false, "")
-	rootCmd.Flags().BoolVar(&useRethForValidation, "use-reth-for-validation", false, "enable flashbots_validateBuilderSubmissionV* on reth and use them for validation")
-	rootCmd.Flags().Uint64Var(&secondaryBuilderPort, "secondary", 1234, "port to use for the secondary builder")
+	addStartFlags(rootCmd.Flags())
+	rootCmd.Flags().BoolVar(&reuseFlag, "reuse", false, "if a playground with the same flags is running in the output folder, print its endpoints instead of starting a new one")
 
 	downloadArtifactsCmd.Flags().BoolVar(&validateFlag, "validate", false, "")
+	addVersionFlags(downloadArtifactsCmd.Flags())
 	watchCmd.Flags().Uint64Var(&numBlocksValidate, "validate-num-blocks", 5, "")
 	watchCmd.Flags().BoolVar(&validatePayloads, "validate-payloads", false, "")
+	healthCmd.Flags().BoolVar(&healthFollowFlag, "follow", false, "refresh the summary periodically")
+	healthCmd.Flags().DurationVar(&healthIntervalFlag, "interval", 2*time.Second, "refresh interval with --follow")
+	addStartFlags(testCmd.Flags())
+	addStartFlags(matrixCmd.Flags())
+	matrixCmd.Flags().StringVar(&matrixConfigFlag, "config", "", "yaml file with the versions of each client to test")
+	matrixCmd.Flags().StringVar(&junitFlag, "junit", "", "write the results of each combination as a JUnit XML report to this file")
+	reportCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	hostsCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	migrateOutputCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	hostsCmd.Flags().StringVar(&hostsNameFlag, "name", "", "name of the playground to include in the hostnames (<service>.<name>.playground.local)")
+	reportCmd.Flags().StringVar(&reportFileFlag, "file", "", "path of the report (defaults to playground-report-<time>.tar.gz)")
+	pauseCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	resumeCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	resumeCmd.Flags().BoolVar(&resumeAllFlag, "all", false, "resume all the services")
+	snapshotCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	snapshotCmd.Flags().StringVar(&snapshotOutFlag, "out", "", "folder to copy the chain to")
+	statusCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	statusCmd.Flags().BoolVar(&statusJSONFlag, "json", false, "print the status as json")
+	statusCmd.Flags().BoolVar(&statusStatsFlag, "stats", false, "sample the cpu and memory usage of the process of each service")
+	lsCmd.Flags().BoolVar(&lsJSONFlag, "json", false, "print the sessions as json")
+	envCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	envCmd.Flags().BoolVar(&envShellFlag, "shell", false, "start a shell with the environment variables instead of printing them")
+	forwardCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	forwardCmd.Flags().StringVar(&forwardAddressFlag, "address", "0.0.0.0", "interface to listen on")
+	forwardCmd.Flags().IntVar(&forwardPortFlag, "port", 0, "port to listen on (a random one if not set)")
+	for _, cmd := range []*cobra.Command{partitionCreateCmd, partitionHealCmd} {
+		cmd.Flags().StringVar(&outputFlag, "output", "", "")
+		cmd.Flags().BoolVar(&partitionDryRunFlag, "dry-run", false, "print the iptables commands instead of running them")
+	}
+	waitCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	waitCmd.Flags().StringArrayVar(&waitForFlag, "for", nil, "condition to wait for: service:<name>:<state>, block>=<number> or slot>=<number> (can be repeated)")
+	waitCmd.Flags().DurationVar(&waitTimeoutFlag, "timeout", 5*time.Minute, "maximum time to wait for the conditions")
+	waitCmd.Flags().DurationVar(&waitIntervalFlag, "interval", time.Second, "interval between the checks of the conditions")
+	for _, cmd := range []*cobra.Command{chaosNetemCmd, chaosPauseCmd, chaosHealCmd} {
+		cmd.Flags().StringVar(&outputFlag, "output", "", "")
+	}
+	for _, cmd := range []*cobra.Command{chaosNetemCmd, chaosHealCmd} {
+		cmd.Flags().BoolVar(&chaosDryRunFlag, "dry-run", false, "print the tc commands instead of running them")
+	}
+	chaosNetemCmd.Flags().DurationVar(&chaosDelayFlag, "delay", 0, "latency added to the packets")
+	chaosNetemCmd.Flags().DurationVar(&chaosJitterFlag, "jitter", 0, "variation of the latency")
+	chaosNetemCmd.Flags().StringVar(&chaosLossFlag, "loss", "", "percentage of packets dropped (i.e. 1%)")
+	chaosPauseCmd.Flags().DurationVar(&chaosPauseEveryFlag, "every", 30*time.Second, "interval between the pauses")
+	chaosPauseCmd.Flags().DurationVar(&chaosPauseForFlag, "for", 5*time.Second, "duration of each pause")
+	exportChainCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	outputCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	exportChainCmd.Flags().Uint64Var(&exportFromFlag, "from", 0, "first block number and slot to export")
+	exportChainCmd.Flags().StringVar(&exportToFlag, "to", "head", "last block number and slot to export, or head")
+	exportChainCmd.Flags().StringVar(&exportFormatFlag, "format", exportJSONL, "format of the files: jsonl")
+	rpcReplayCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	rpcReplayCmd.Flags().StringVar(&rpcReplayFileFlag, "file", "", "recording to replay (defaults to the one of the output folder)")
+	rpcReplayCmd.Flags().StringVar(&rpcReplayTargetFlag, "target", "http://localhost:8545", "url of the EL to replay the requests against")
+	rpcReplayCmd.Flags().BoolVar(&rpcReplayVerboseFlag, "verbose", false, "print the requests with a different response")
+	testCmd.Flags().StringVar(&junitFlag, "junit", "", "write the results of the scenarios as a JUnit XML report to this file")
+	inspectCmd.Flags().BoolVar(&inspectResolvedFlag, "resolved", false, "show the command line and the environment variables of the services")
+	bundleCmd.Flags().StringVar(&outputFlag, "output", "", "")
+	bundleCmd.Flags().StringVar(&bundleFileFlag, "file", "", "path of the bundle (defaults to playground-bundle-<time>.tar.gz)")
+	replayCmd.Flags().StringVar(&outputFlag, "output", "", "folder to extract the bundle and run reth (defaults to $HOME/.playground/replay)")
+	replayCmd.Flags().StringVar(&rethVersionFlag, "reth-version", "", "release of reth to download instead of the default one")
+	replayCmd.Flags().BoolVar(&offlineFlag, "offline", false, "only use the releases already downloaded")
+	replayCmd.Flags().Float64Var(&replaySpeedFlag, "speed", 1, "speed of the replay relative to the recorded timing (0 sends the requests without waiting)")
+	replayCmd.Flags().BoolVar(&replayVerboseFlag, "verbose", false, "print the requests with a different response")
 
 	rootCmd.AddCommand(downloadArtifactsCmd)
 	rootCmd.AddCommand(watchCmd)
-	if err := rootCmd.Execute(); err != nil {
+	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(matrixCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(hostsCmd)
+	rootCmd.AddCommand(migrateOutputCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rpcCmd.AddCommand(rpcReplayCmd)
+	rootCmd.AddCommand(rpcCmd)
+	rootCmd.AddCommand(importKurtosisCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(forwardCmd)
+	rootCmd.AddCommand(waitCmd)
+	chaosCmd.AddCommand(chaosNetemCmd)
+	chaosCmd.AddCommand(chaosPauseCmd)
+	chaosCmd.AddCommand(chaosHealCmd)
+	rootCmd.AddCommand(chaosCmd)
+	rootCmd.AddCommand(exportChainCmd)
+	rootCmd.AddCommand(outputCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(replayCmd)
+	partitionCmd.AddCommand(partitionCreateCmd)
+	partitionCmd.AddCommand(partitionHealCmd)
+	rootCmd.AddCommand(partitionCmd)
+	registerCompletions()
+
+	// the context of the commands is cancelled with Ctrl+C or a SIGTERM (i.e. from timeout,
+	// systemd or a CI runner), so the services in their own process group are stopped too
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func runIt() error {
-	if genesisDelayFlag < minimumGenesisDelay {
-		return fmt.Errorf("genesis delay must be at least %d", minimumGenesisDelay)
-	}
-
-	if outputFlag == "" {
-		// Use the $HOMEDIR/devnet as the default output
-		homeDir, err := getHomeDir()
-		if err != nil {
-			return err
-		}
-		outputFlag = filepath.Join(homeDir, "devnet")
-	}
+// addStartFlags registers the flags to configure and start the playground
+func addStartFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&outputFlag, "output", "", "")
+	flags.BoolVar(&continueFlag, "continue", false, "")
+	flags.StringVar(&fromSnapshotFlag, "from-snapshot", "", "replace the output folder with a snapshot (see the snapshot command) and continue its chain")
+	flags.BoolVar(&useBinPathFlag, "use-bin-path", false, "")
+	addVersionFlags(flags)
+	flags.Uint64Var(&genesisDelayFlag, "genesis-delay", minimumGenesisDelay, "")
+	flags.StringVar(&logFormatFlag, "log-format", logText, "format of the log of the playground: text or json")
+	flags.StringVar(&logLevelFlag, "log-level", "info", "level of the log of the playground: debug, info, warn or error")
+	flags.StringVar(&progressFormatFlag, "progress-format", progressText, "format of the progress of the playground: text or json (one event per line in stdout, the rest of the output goes to stderr)")
+	flags.BoolVar(&relayRequestLogFlag, "relay-request-log", false, "log every request to the relay api in the output folder")
+	flags.Float64Var(&relayLoadgenRateFlag, "relay-loadgen-rate", 0, "submit this many synthetic blocks per second to the relay (disabled if 0)")
+	flags.Uint64Var(&buildersFlag, "builders", 0, "run this number of relay load generators as builders that compete in the auctions of the relay")
+	flags.StringVar(&relayLoadgenValueFlag, "relay-loadgen-value", "1-100", "values of the synthetic blocks in gwei: <min>-<max> (uniform) or exp:<mean> (exponential)")
+	flags.BoolVar(&artifactsCacheFlag, "artifacts-cache", false, "reuse the genesis generated by any previous run with the same config and validators from $HOME/.playground/genesis-cache")
+	flags.Uint64Var(&startSlotFlag, "start-slot", 0, "slot of the chain when the services are ready (i.e. 31 for the first block at the end of an epoch)")
+	flags.BoolVar(&latestForkFlag, "electra", false, "")
+	flags.BoolVar(&useRethForValidation, "use-reth-for-validation", false, "enable flashbots_validateBuilderSubmissionV* on reth and use them for validation")
+	flags.StringVar(&validationServerAddrFlag, "validation-server-addr", "", "url of a node with flashbots_validateBuilderSubmissionV* to validate the builder submissions of the relay")
+	flags.Uint64Var(&secondaryBuilderPort, "secondary", 0, "port of a secondary builder that receives a copy of the engine api requests of the beacon node (i.e. 1234)")
+	flags.BoolVar(&clProxyCompareFlag, "cl-proxy-compare", false, "send the newPayload and forkchoiceUpdated requests unmodified to the secondary builder and report the responses that differ from reth")
+	flags.BoolVar(&uniqueKeysFlag, "unique-keys", false, "generate new keys for the components instead of using the well-known ones")
+	flags.StringVar(&networkFlag, "network", "", "sync an existing public testnet (sepolia, holesky, hoodi) instead of creating a local devnet")
+	flags.StringVar(&checkpointSyncURLFlag, "checkpoint-sync-url", "", "checkpoint sync url for the beacon node when --network is set")
+	flags.StringVar(&uploadArtifactsFlag, "upload-artifacts", os.Getenv("PLAYGROUND_UPLOAD_ARTIFACTS"), "upload the output folder to s3://bucket/prefix or gs://bucket/prefix when the playground stops")
+	flags.DurationVar(&stopGracePeriodFlag, "stop-grace-period", 10*time.Second, "time to wait for the services to exit after SIGTERM before killing them")
+	flags.BoolVar(&withForkmonFlag, "with-forkmon", false, "serve a dashboard with the head, peers and reorgs of the execution nodes")
+	flags.BoolVar(&recordRPCFlag, "record-rpc", false, "serve a proxy of the EL http endpoint that records the requests and responses")
+	flags.BoolVar(&recordEngineFlag, "record-engine", false, "record the engine api requests of the beacon node and the responses of reth")
+	flags.StringSliceVar(&featuresFlag, "feature", nil, featuresHelp())
+	flags.IntVar(&numELNodesFlag, "num-el-nodes", 1, "number of reth nodes, the additional ones follow the chain of the first one")
+	flags.StringSliceVar(&serviceResourcesFlag, "service-resources", nil, "limit the cpus and the memory of the services: <service>=<cpus>:<memory> (i.e. reth=2:4GB)")
+	flags.StringArrayVar(&overrideFlag, "override", nil, "extra args or env of a service: <service>.args+=<args> or <service>.env.<name>=<value> (i.e. reth.args+=--txpool.pending-max-count=20000)")
+	flags.StringArrayVar(&chaosFlag, "chaos", nil, "inject faults in a service: <service>=<fault>:<value>,... with the faults delay, jitter, loss, pause-every and pause-for (i.e. reth=delay:100ms,loss:1%)")
+	flags.StringSliceVar(&skewFlag, "skew", nil, "shift the clock of the services with libfaketime: <service>=<offset> (i.e. reth=+2s or beacon_node=-500ms)")
+	flags.StringSliceVar(&buildFromSourceFlag, "build-from-source", nil, "build a release binary from a local checkout instead of downloading it: <release>=<path> (i.e. reth=../reth)")
+	flags.StringVar(&freezeAtFlag, "freeze-at", "", "pause all the services when the chain reaches block=<number> or slot=<number>")
+	flags.BoolVar(&freezeSnapshotFlag, "freeze-snapshot", false, "copy the output folder when the services are frozen by --freeze-at")
+	flags.StringVar(&maxDiskFlag, "max-disk", "", "stop the playground when the output folder exceeds this size (i.e. 50GB)")
+	flags.BoolVar(&noDegradeFlag, "no-degrade", false, "do not run reth as a pruned node with less logging when the host has low resources")
+	flags.StringSliceVar(&envPassthroughFlag, "env-passthrough", nil, "only pass these environment variables (VAR or service:VAR) to the services, besides the base and proxy ones")
+	flags.StringVar(&uploadArtifactsRetentionFlag, "upload-artifacts-retention", os.Getenv("PLAYGROUND_UPLOAD_ARTIFACTS_RETENTION"), "retention tag attached to the uploaded artifacts")
+}
 
-	fmt.Printf("Output directory: %s\n", outputFlag)
-	out := &output{dst: outputFlag}
+func addVersionFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&rethVersionFlag, "reth-version", "", "release of reth to download instead of the default one")
+	flags.StringVar(&lighthouseVersionFlag, "lighthouse-version", "", "release of lighthouse to download instead of the default one")
+	flags.StringVar(&prysmVersionFlag, "prysm-version", "", "release of prysm to download instead of the default one")
+	flags.StringVar(&clClientFlag, "cl-client", clLighthouse, "consensus client of the beacon node and the validator (lighthouse or prysm)")
+	flags.Uint64Var(&numValidatorsFlag, "num-validators", defaultNumValidators, "number of validators of the local devnet")
+	flags.StringVar(&prefundedBalanceFlag, "prefunded-balance", "", "balance of each prefunded account in wei or in ether with the eth suffix (i.e. 1000eth)")
+	flags.Uint64Var(&vcCountFlag, "vc-count", 1, "split the validator keys across this number of validator clients of --cl-client")
+	flags.StringSliceVar(&validatorSplitFlag, "validator-split", nil, "split the validator keys across several validator clients of these types (i.e. lighthouse,prysm)")
+	flags.BoolVar(&offlineFlag, "offline", false, "only use the releases already downloaded and fail with the missing ones")
+	flags.IntVar(&downloadRetriesFlag, "download-retries", artifacts.DefaultDownloadConfig().Retries, "number of retries of a failed download of a release")
+	flags.StringSliceVar(&downloadMirrorsFlag, "download-mirror", nil, "base urls to download the releases from when GitHub fails, in order")
+}
 
-	exists := out.Exists("data_reth")
-	if exists {
-		if continueFlag {
-			fmt.Println("Artifacts already exist, continuing...")
-		} else {
-			fmt.Println("Artifacts already exist, resetting them...")
+// downloadConfig returns the settings of the downloads of the releases set with the flags
+func downloadConfig() *artifacts.DownloadConfig {
+	cfg := artifacts.DefaultDownloadConfig()
+	cfg.Retries = downloadRetriesFlag
+	cfg.Mirrors = downloadMirrorsFlag
+	cfg.Offline = offlineFlag
+	return cfg
+}
 
-			// Remove the current artifacts and create new ones
-			if err := out.Remove(""); err != nil {
-				return err
-			}
-			if err := setupArtifacts(); err != nil {
-				return err
-			}
-		}
-	} else {
-		// artifacts do not exist yet, create them
-		if err := setupArtifacts(); err != nil {
-			return err
-		}
+// releaseVersions returns the release versions set with the flags
+func releaseVersions() map[string]string {
+	return map[string]string{
+		"reth":         rethVersionFlag,
+		"lighthouse":   lighthouseVersionFlag,
+		"beacon-chain": prysmVersionFlag,
+		"validator":    prysmVersionFlag,
 	}
+}
 
-	svcManager := newServiceManager(out)
-	if err := setupServices(svcManager, out); err != nil {
-		// close all services if there was an error
-		svcManager.StopAndWait()
+func runIt(ctx context.Context) error {
+	sess, err := startSession(ctx)
+	if err != nil {
 		return err
 	}
 
-	go watchProposerPayloads()
-
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-
 	select {
-	case <-sig:
-		fmt.Println("Stopping...")
-	case <-svcManager.NotifyErrCh():
+	case <-ctx.Done():
+		logger.Info("Stopping...")
+	case <-sess.svcManager.NotifyErrCh():
 	}
 
-	svcManager.StopAndWait()
+	sess.Stop()
 	return nil
 }
 
-func setupArtifacts() error {
+// setupArtifacts generates the genesis artifacts of the chain. If a snapshot of the genesis
+// of a previous run is provided, it is reused and patched with the new EL genesis block.
+func setupArtifacts(keys *keyRegistry, snapshot *genesisSnapshot) error {
 	out := &output{dst: outputFlag}
 
 	// enable the latest fork in config.yaml or not
 	var latestForkEpoch string
-	if latestForkFlag {
+	if features.Enabled(featureElectra) {
 		latestForkEpoch = "0"
 	} else {
 		latestForkEpoch = "18446744073709551615"
@@ -290,13 +464,21 @@ func setupArtifacts() error {
 		return err
 	}
 
-	genesisTime := uint64(time.Now().Add(time.Duration(genesisDelayFlag) * time.Second).Unix())
 	config := params.BeaconConfig()
 
+	// with --start-slot the genesis is moved to the past so that the chain is at that
+	// slot once the services are running
+	genesisTime := uint64(time.Now().Add(time.Duration(genesisDelayFlag) * time.Second).Unix())
+	offset := startSlotFlag * config.SecondsPerSlot
+	if offset >= genesisTime {
+		return fmt.Errorf("--start-slot %d is before the unix epoch", startSlotFlag)
+	}
+	genesisTime -= offset
+
 	gen := interop.GethTestnetGenesis(genesisTime, config)
 
 	// add pre-funded accounts
-	prefundedBalance, _ := new(big.Int).SetString("10000000000000000000000", 16)
+	balance := prefundedBalance()
 
 	for _, privStr := range prefundedAccounts {
 		priv, err := getPrivKey(privStr)
@@ -305,7 +487,7 @@ func setupArtifacts() error {
 		}
 		addr := ecrypto.PubkeyToAddress(priv.PublicKey)
 		gen.Alloc[addr] = types.Account{
-			Balance: prefundedBalance,
+			Balance: balance,
 			Nonce:   1,
 		}
 	}
@@ -313,44 +495,106 @@ func setupArtifacts() error {
 	block := gen.ToBlock()
 
 	var v int
-	if latestForkFlag {
+	if features.Enabled(featureElectra) {
 		v = version.Electra
 	} else {
 		v = version.Deneb
 	}
 
-	priv, pub, err := interop.DeterministicallyGenerateKeys(0, 100)
-	if err != nil {
-		return err
+	var (
+		genesisState state.BeaconState
+		keystore     encObject
+	)
+	// with --artifacts-cache, the cached genesis takes precedence over the one of the
+	// previous run since it was generated with the same inputs
+	cacheKey := genesisCacheKey(clConfigContentStr, v, numValidatorsFlag)
+	var cached bool
+	if artifactsCacheFlag {
+		if cachedSnapshot, err := loadCachedGenesis(cacheKey); err == nil {
+			snapshot, cached = cachedSnapshot, true
+		} else if !os.IsNotExist(err) {
+			logger.Warn("Could not load the cached genesis", "err", err)
+		}
 	}
 
-	depositData, roots, err := interop.DepositDataFromKeysWithExecCreds(priv, pub, 100)
-	if err != nil {
-		return err
+	if snapshot != nil {
+		if genesisState, err = snapshot.beaconState(v, int(numValidatorsFlag)); err == nil {
+			err = patchGenesis(genesisState, genesisTime, v, block)
+		}
+		if err != nil {
+			if cached {
+				logger.Warn("Could not reuse the cached genesis", "key", cacheKey, "err", err)
+			} else {
+				logger.Warn("Could not reuse the genesis of the previous run", "err", err)
+			}
+			genesisState = nil
+			cached = false
+		} else if cached {
+			logger.Info("Reusing the cached genesis", "key", cacheKey)
+			keystore = snapshot
+		} else {
+			logger.Info("Reusing the genesis of the previous run")
+			keystore = snapshot
+		}
 	}
 
-	opts := make([]interop.PremineGenesisOpt, 0)
-	opts = append(opts, interop.WithDepositData(depositData, roots))
+	if genesisState == nil {
+		priv, pub, err := interop.DeterministicallyGenerateKeys(0, numValidatorsFlag)
+		if err != nil {
+			return err
+		}
 
-	state, err := interop.NewPreminedGenesis(context.Background(), genesisTime, 0, 100, v, block, opts...)
-	if err != nil {
-		return err
+		depositData, roots, err := interop.DepositDataFromKeysWithExecCreds(priv, pub, numValidatorsFlag)
+		if err != nil {
+			return err
+		}
+
+		opts := make([]interop.PremineGenesisOpt, 0)
+		opts = append(opts, interop.WithDepositData(depositData, roots))
+
+		genesisState, err = interop.NewPreminedGenesis(context.Background(), genesisTime, 0, numValidatorsFlag, v, block, opts...)
+		if err != nil {
+			return err
+		}
+		keystore = &lighthouseKeystore{privKeys: priv}
+	}
+
+	if artifactsCacheFlag && !cached {
+		if err := storeCachedGenesis(cacheKey, genesisState, keystore); err != nil {
+			logger.Warn("Could not cache the genesis", "err", err)
+		}
 	}
 
 	err = out.WriteBatch(map[string]interface{}{
 		"testnet/config.yaml":                 func() ([]byte, error) { return convert(config) },
-		"testnet/genesis.ssz":                 state,
+		"testnet/genesis.ssz":                 genesisState,
 		"genesis.json":                        gen,
-		"jwtsecret":                           defaultJWTToken,
 		"testnet/boot_enr.yaml":               "[]",
 		"testnet/deploy_block.txt":            "0",
 		"testnet/deposit_contract_block.txt":  "0",
-		"testnet/genesis_validators_root.txt": hex.EncodeToString(state.GenesisValidatorsRoot()),
-		"data_validator/":                     &lighthouseKeystore{privKeys: priv},
+		"testnet/genesis_validators_root.txt": hex.EncodeToString(genesisState.GenesisValidatorsRoot()),
+		validatorKeystoresArtifact + "/":      keystore,
 	})
 	if err != nil {
 		return err
 	}
+	if err := out.WriteBatch(keys.Artifacts()); err != nil {
+		return err
+	}
+	if err := writeValidatorSplit(out); err != nil {
+		return err
+	}
+	if err := out.WriteBatch(clArtifacts()); err != nil {
+		return err
+	}
+
+	kurtosis, err := newKurtosisParams(config, numValidatorsFlag)
+	if err != nil {
+		return err
+	}
+	if err := out.WriteFile(kurtosisParamsArtifact, kurtosis); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -368,35 +612,46 @@ func getPrivKey(privStr string) (*ecdsa.PrivateKey, error) {
 	return priv, nil
 }
 
-func setupServices(svcManager *serviceManager, out *output) error {
-	var (
-		rethBin, lighthouseBin string
-	)
+func setupServices(svcManager *serviceManager, out *output, keys *keyRegistry) error {
+	// the releases built from source are not downloaded
+	names := slices.DeleteFunc(releaseNames(), func(name string) bool {
+		return slices.ContainsFunc(sourceBuilds, func(b *sourceBuild) bool { return b.name == name })
+	})
 
+	bins := map[string]string{}
 	if useBinPathFlag {
-		fmt.Println("Using binaries from the PATH")
+		logger.Info("Using binaries from the PATH")
 
-		rethBin = "reth"
-		lighthouseBin = "lighthouse"
+		for _, name := range names {
+			bins[name] = name
+		}
 	} else {
-		binArtifacts, err := artifacts.DownloadArtifacts()
-		if err != nil {
+		var err error
+		if bins, err = artifacts.DownloadArtifacts(names, releaseVersions(), downloadConfig()); err != nil {
 			return err
 		}
-
-		rethBin = binArtifacts["reth"]
-		lighthouseBin = binArtifacts["lighthouse"]
+		for name, path := range bins {
+			emitProgress(&progressEvent{Type: progressDownloaded, Service: name, Message: path})
+		}
 	}
-
-	// log the prefunded accounts
-	fmt.Printf("\nPrefunded accounts:\n==================\n")
-	for indx, acc := range prefundedAccounts {
-		priv, _ := getPrivKey(acc)
-		fmt.Printf("(%d) %s (%s)\n", indx, acc, ecrypto.PubkeyToAddress(priv.PublicKey).Hex())
+	for _, b := range sourceBuilds {
+		bin, err := b.build(out)
+		if err != nil {
+			return err
+		}
+		bins[b.name] = bin
+		emitProgress(&progressEvent{Type: progressDownloaded, Service: b.name, Message: bin})
 	}
-	fmt.Println("")
-	if err := os.WriteFile(defaultRethDiscoveryPrivKeyLoc, []byte(defaultRethDiscoveryPrivKey), 0644); err != nil {
-		return err
+	rethBin := bins["reth"]
+
+	if networkFlag == "" {
+		// log the prefunded accounts
+		fmt.Printf("\nPrefunded accounts:\n==================\n")
+		for indx, acc := range prefundedAccounts {
+			priv, _ := getPrivKey(acc)
+			fmt.Printf("(%d) %s (%s)\n", indx, acc, ecrypto.PubkeyToAddress(priv.PublicKey).Hex())
+		}
+		fmt.Println("")
 	}
 
 	// Start the cl proxy
@@ -407,21 +662,57 @@ func setupServices(svcManager *serviceManager, out *output) error {
 		if secondaryBuilderPort != 0 {
 			cfg.Secondary = fmt.Sprintf("http://localhost:%d", secondaryBuilderPort)
 		}
+		cfg.Compare = clProxyCompareFlag
+		cfg.Followers = map[string]string{}
+		for _, f := range rethFollowers() {
+			cfg.Followers[f.name] = fmt.Sprintf("http://localhost:%d", f.AuthRPCPort())
+		}
+		if features.Enabled(featureSplitJWT) {
+			// the proxy checks the token of the beacon node and signs the requests to each
+			// target with the secret of the target
+			cfg.JWTSecret = keys.EngineJWTSecret(jwtConnBeaconNode)
+			cfg.TargetJWTSecrets = map[string]string{
+				"primary":        keys.EngineJWTSecret("reth"),
+				jwtConnSecondary: keys.EngineJWTSecret(jwtConnSecondary),
+			}
+			for name := range cfg.Followers {
+				cfg.TargetJWTSecrets[name] = keys.EngineJWTSecret(name)
+			}
+		}
 
 		var err error
 		if cfg.LogOutput, err = out.LogOutput("cl-proxy"); err != nil {
 			return err
 		}
+		var engineRecording *os.File
+		if recordEngineFlag {
+			if engineRecording, err = os.Create(filepath.Join(out.dst, engineRecordingArtifact)); err != nil {
+				return err
+			}
+			cfg.Recording = engineRecording
+		}
 		clproxy, err := clproxy.New(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to create cl proxy: %w", err)
 		}
 
-		go func() {
-			if err := clproxy.Run(); err != nil {
-				svcManager.emitError()
+		svcManager.RunInProcess("cl-proxy", clproxy.Run, func() error {
+			if err := clproxy.Close(); err != nil {
+				return err
+			}
+			if engineRecording != nil {
+				return engineRecording.Close()
+			}
+			return nil
+		})
+		// report when the cl-proxy stops being able to reach any of its
+		// targets (i.e. the secondary builder is down) and when it recovers.
+		svcManager.NewCronJob("cl-proxy-health", 2*time.Second, func(ctx context.Context) error {
+			if err := clproxy.Healthy(); err != nil {
+				return fmt.Errorf("cl-proxy is unhealthy: %w", err)
 			}
-		}()
+			return nil
+		})
 	}
 
 	rethVersion := func() string {
@@ -445,126 +736,95 @@ func setupServices(svcManager *serviceManager, out *output) error {
 	}()
 
 	// start the reth el client
-	fmt.Println("Starting reth version " + rethVersion)
-	svcManager.
-		NewService("reth").
-		WithArgs(
-			rethBin,
-			"node",
-			"--chain", "{{.Dir}}/genesis.json",
-			"--datadir", "{{.Dir}}/data_reth",
-			"--color", "never",
-			"--ipcpath", "{{.Dir}}/reth.ipc",
-			// p2p config. Use a default discovery key and disable public discovery and connections
-			"--p2p-secret-key", defaultRethDiscoveryPrivKeyLoc,
-			"--addr", "127.0.0.1",
-			"--port", "30303",
-			// "--disable-discovery",
-			// http config
-			"--http",
-			"--http.api", "admin,eth,net,web3",
-			"--http.port", "8545",
-			"--authrpc.port", "8551",
-			"--authrpc.jwtsecret", "{{.Dir}}/jwtsecret",
-			"-vvvv",
-		).
-		If(useRethForValidation, func(s *service) *service {
-			return s.WithReplacementArgs("--http.api", "admin,eth,web3,net,rpc,flashbots")
-		}).
-		If(
-			semver.Compare(rethVersion, "v1.1.0") >= 0,
-			func(s *service) *service {
-				// For versions >= v1.1.0, we need to run with --engine.legacy, at least for now
-				return s.WithArgs("--engine.legacy")
-			},
-		).
-		WithPort("rpc", 30303).
-		WithPort("http", 8545).
-		WithPort("authrpc", 8551).
+	logger.Info("Starting reth", "version", rethVersion)
+	if err := checkComponentVersion("reth", rethVersion); err != nil {
+		return err
+	}
+	if err := out.WriteBatch(engineJWTArtifacts(keys)); err != nil {
+		return err
+	}
+	newReth := func(name string) *service {
+		return svcManager.
+			NewService(name).
+			WithArgs(
+				rethBin,
+				"node",
+				"--chain", "{{.Dir}}/genesis.json",
+				"--datadir", "{{.Dir}}/data_reth",
+				"--color", "never",
+				"--ipcpath", "{{.Dir}}/reth.ipc",
+				// p2p config. Use a default discovery key and disable public discovery and connections
+				"--p2p-secret-key", "{{.Dir}}/"+rethP2PKeyArtifact,
+				"--addr", "127.0.0.1",
+				"--port", "30303",
+				// "--disable-discovery",
+				// http config
+				"--http",
+				"--http.api", "admin,eth,net,web3",
+				"--http.port", "8545",
+				"--authrpc.port", "8551",
+				"--authrpc.jwtsecret", "{{.Dir}}/"+engineJWTArtifact(name),
+				"-vvvv",
+			).
+			If(features.Enabled(featureRethValidation), func(s *service) *service {
+				return s.WithReplacementArgs("--http.api", "admin,eth,web3,net,rpc,flashbots")
+			}).
+			If(features.Enabled(featureLowResources), func(s *service) *service {
+				// run as a pruned node instead of an archive node and log less
+				return s.WithReplacementArgs("-vvvv", "-vvv").WithArgs("--full")
+			}).
+			If(networkFlag != "", func(s *service) *service {
+				// use the built-in chain spec and accept connections from the public peers
+				return s.WithReplacementArgs("--chain", networkFlag).WithReplacementArgs("--addr", "0.0.0.0")
+			}).
+			WithVersionArgs("reth", rethVersion)
+	}
+	// the stages of a previous run are not valid for the new processes
+	for _, service := range []string{"reth", "beacon_node"} {
+		if err := out.Remove(readinessArtifact(service, p2pReadinessStage)); err != nil {
+			return err
+		}
+	}
+	svcManager.NewCronJob("p2p-readiness", time.Second, newP2PReadinessWatcher(out))
+
+	newReth("reth").
+		WithPort("rpc", 30303, protocolP2P).
+		WithPort("http", 8545, protocolHTTP).
+		WithPort("authrpc", 8551, protocolEngineAPI).
 		Run()
 
-	lightHouseVersion := func() string {
-		cmd := exec.Command(lighthouseBin, "--version")
-		out, err := cmd.Output()
+	if followers := rethFollowers(); len(followers) != 0 {
+		rethEnode, err := keys.RethEnode()
 		if err != nil {
-			return "unknown"
+			return err
 		}
-		// find the line of the form:
-		// Lighthouse v5.2.1-9e12c21
-		for _, line := range strings.Split(string(out), "\n") {
-			if strings.HasPrefix(line, "Lighthouse ") {
-				v := strings.TrimSpace(strings.TrimPrefix(line, "Lighthouse "))
-				if !strings.HasPrefix(v, "v") {
-					v = "v" + v
-				}
-				// Go semver considers - as a pre-release, so we need to remove it
-				v = strings.Split(v, "-")[0]
-				return semver.Canonical(v)
+		for _, f := range followers {
+			if err := out.WriteFile(f.P2PKeyArtifact(), keys.RethFollowerP2PKey(f.index)); err != nil {
+				return err
 			}
+			newReth(f.name).
+				WithReplacementArgs("--datadir", "{{.Dir}}/data_"+f.name).
+				WithReplacementArgs("--ipcpath", "{{.Dir}}/"+f.name+".ipc").
+				WithReplacementArgs("--p2p-secret-key", "{{.Dir}}/"+f.P2PKeyArtifact()).
+				WithReplacementArgs("--port", strconv.Itoa(f.P2PPort())).
+				WithReplacementArgs("--http.port", strconv.Itoa(f.HTTPPort())).
+				WithReplacementArgs("--authrpc.port", strconv.Itoa(f.AuthRPCPort())).
+				WithArgs(
+					"--discovery.port", strconv.Itoa(f.P2PPort()),
+					"--trusted-peers", rethEnode,
+				).
+				WithPort("rpc", f.P2PPort(), protocolP2P).
+				WithPort("http", f.HTTPPort(), protocolHTTP).
+				WithPort("authrpc", f.AuthRPCPort(), protocolEngineAPI).
+				WithDependency("reth", "rpc", dependencyP2P).
+				DependsOnArtifact(readinessArtifact("reth", p2pReadinessStage)).
+				Run()
 		}
-		return "unknown"
-	}()
-
-	// start the beacon node
-	fmt.Println("Starting lighthouse version " + lightHouseVersion)
-	svcManager.
-		NewService("beacon_node").
-		WithArgs(
-			lighthouseBin,
-			"bn",
-			"--datadir", "{{.Dir}}/data_beacon_node",
-			"--testnet-dir", "{{.Dir}}/testnet",
-			"--enable-private-discovery",
-			"--disable-peer-scoring",
-			"--staking",
-			"--enr-address", "127.0.0.1",
-			"--enr-udp-port", "9000",
-			"--enr-tcp-port", "9000",
-			"--enr-quic-port", "9100",
-			"--port", "9000",
-			"--quic-port", "9100",
-			"--http-port", "3500",
-			"--disable-packet-filter",
-			"--target-peers", "0",
-			"--execution-endpoint", "http://localhost:5656",
-			"--execution-jwt", "{{.Dir}}/jwtsecret",
-			"--builder", "http://localhost:5555",
-			"--builder-fallback-epochs-since-finalization", "0",
-			"--builder-fallback-disable-checks",
-			"--always-prepare-payload",
-			"--prepare-payload-lookahead", "8000",
-		).
-		If(
-			semver.Compare(lightHouseVersion, "v5.3") < 0,
-			func(s *service) *service {
-				// For versions <= v5.2.1, we want to run with --http-allow-sync-stalled
-				// However this flag is not available in newer versions
-				return s.WithArgs("--http-allow-sync-stalled")
-			},
-		).
-		If(
-			semver.Compare(lightHouseVersion, "v5.3") >= 0,
-			func(s *service) *service {
-				// For versions >= v5.3.0, ----suggested-fee-recipient is apparently now required for non-validator nodes as well
-				return s.WithArgs("--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990")
-			},
-		).
-		WithPort("http", 3500).
-		Run()
+	}
 
-	// start validator client
-	svcManager.
-		NewService("validator").
-		WithArgs(
-			lighthouseBin,
-			"vc",
-			"--datadir", "{{.Dir}}/data_validator",
-			"--testnet-dir", "{{.Dir}}/testnet",
-			"--init-slashing-protection",
-			"--beacon-nodes", "http://localhost:3500",
-			"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990",
-			"--builder-proposals",
-		).Run()
+	if err := runConsensusClient(svcManager, bins); err != nil {
+		return err
+	}
 
 	{
 		cfg := mevboostrelay.DefaultConfig()
@@ -572,36 +832,139 @@ func setupServices(svcManager *serviceManager, out *output) error {
 		if cfg.LogOutput, err = out.LogOutput("mev-boost-relay"); err != nil {
 			return err
 		}
-		cfg.UseRethForValidation = useRethForValidation
+		cfg.UseRethForValidation = features.Enabled(featureRethValidation)
+		cfg.ValidationServerAddr = validationServerAddrFlag
+		cfg.ApiSecretKey = keys.RelaySecretKey()
+		var requestLog *os.File
+		if relayRequestLogFlag {
+			if requestLog, err = os.Create(filepath.Join(out.dst, relayRequestLogArtifact)); err != nil {
+				return err
+			}
+			cfg.RequestLog = requestLog
+		}
 		relay, err := mevboostrelay.New(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to create relay: %w", err)
 		}
 
-		go func() {
-			if err := relay.Start(); err != nil {
-				svcManager.emitError()
+		svcManager.relay = relay
+		svcManager.RunInProcess("mev-boost-relay", relay.Start, func() error {
+			if err := relay.Stop(); err != nil {
+				return err
 			}
-		}()
-	}
-
-	services := []*service{}
-	for _, ss := range svcManager.handles {
-		services = append(services, ss.Service)
-	}
-	services = append(services, &service{
-		name: "mev-boost-relay",
-		ports: []*port{
-			{name: "http", port: 5555},
-		},
-	}, &service{
-		name: "cl-proxy",
-		ports: []*port{
-			{name: "jsonrpc", port: 5656},
-		},
-	})
+			if requestLog != nil {
+				if err := requestLog.Close(); err != nil {
+					return err
+				}
+			}
+			if err := out.WriteFile(relayValidationStatsArtifact, relay.ValidationStats()); err != nil {
+				return err
+			}
+			if err := out.WriteFile(relayWinningBidsArtifact, relay.WinningBids()); err != nil {
+				return err
+			}
+			// the latency stats of the api attribute the slow submissions to the relay or the builder
+			return out.WriteFile(relayAPIStatsArtifact, relay.APIStats())
+		})
+
+		// the stages of a previous run do not apply to the new relay
+		if err := out.Remove(readinessArtifact("mev-boost-relay", "")); err != nil {
+			return err
+		}
+		svcManager.NewCronJob("mev-boost-relay-readiness", time.Second, newRelayReadinessWatcher(out, relay))
+	}
+
+	builderStats := newMockBuilderStats(out)
+	for _, name := range mockBuilders() {
+		cfg := relayloadgen.DefaultConfig()
+		cfg.Rate = mockBuilderRate()
+		var err error
+		if cfg.Value, err = relayloadgen.ParseValueDistribution(relayLoadgenValueFlag); err != nil {
+			return fmt.Errorf("invalid --relay-loadgen-value: %w", err)
+		}
+		if cfg.LogOutput, err = out.LogOutput(name); err != nil {
+			return err
+		}
+		loadgen, err := relayloadgen.New(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to create relay load generator: %w", err)
+		}
+
+		svcManager.RunInProcess(name, loadgen.Run, func() error {
+			if err := loadgen.Close(); err != nil {
+				return err
+			}
+			return builderStats.write(name, loadgen.Stats())
+		})
+	}
+
+	if withForkmonFlag {
+		nodes := map[string]string{
+			"reth": "http://localhost:8545",
+		}
+		for _, f := range rethFollowers() {
+			nodes[f.name] = fmt.Sprintf("http://localhost:%d", f.HTTPPort())
+		}
+		monitor := newForkmon(nodes)
+		srv := monitor.Server(forkmonPort)
+		svcManager.RunInProcess("forkmon", serveHTTP(srv), srv.Close)
+		svcManager.NewCronJob("forkmon", time.Second, monitor.Update)
+	}
+
+	if recordRPCFlag {
+		recorder, err := newRPCRecorder("http://localhost:8545", filepath.Join(out.dst, rpcRecordingArtifact))
+		if err != nil {
+			return fmt.Errorf("failed to create the rpc recorder: %w", err)
+		}
+		srv := recorder.Server(rpcRecorderPort)
+		svcManager.RunInProcess("rpc-recorder", serveHTTP(srv), func() error {
+			if err := srv.Close(); err != nil {
+				return err
+			}
+			return recorder.Close()
+		})
+	}
+
+	// the services running inside the playground process
+	relaySvc := (&service{name: "mev-boost-relay", inProcess: true}).
+		WithPort("http", 5555, protocolHTTP).
+		WithPort("auction", 5556, protocolHTTP).
+		WithDependency("beacon_node", "http", dependencyBeaconAPI).
+		If(features.Enabled(featureRethValidation), func(s *service) *service {
+			return s.WithDependency("reth", "http", dependencyRPC)
+		})
+	clProxySvc := (&service{name: "cl-proxy", inProcess: true}).
+		WithPort("jsonrpc", 5656, protocolEngineAPI).
+		WithPort("metrics", 5657, protocolHTTP).
+		WithDependency("reth", "authrpc", dependencyEngineAPI)
+	for _, f := range rethFollowers() {
+		clProxySvc.WithDependency(f.name, "authrpc", dependencyEngineAPI)
+	}
+
+	services := slices.Clone(svcManager.services)
+	services = append(services, relaySvc, clProxySvc)
+	if withForkmonFlag {
+		services = append(services, (&service{name: "forkmon", inProcess: true}).
+			WithPort("http", forkmonPort, protocolHTTP).
+			WithDependency("reth", "http", dependencyRPC))
+	}
+	for _, name := range mockBuilders() {
+		services = append(services, (&service{name: name, inProcess: true}).
+			WithDependency("mev-boost-relay", "http", dependencyBuilderAPI).
+			WithDependency("beacon_node", "http", dependencyBeaconAPI))
+	}
+	if recordRPCFlag {
+		services = append(services, (&service{name: "rpc-recorder", inProcess: true}).
+			WithPort("http", rpcRecorderPort, protocolHTTP).
+			WithDependency("reth", "http", dependencyRPC))
+	}
+
+	if err := out.WriteBatch(newTopology(services).Artifacts()); err != nil {
+		return err
+	}
 
 	// print services info
+	endpoints := map[string]map[string]string{}
 	fmt.Printf("Services started:\n==================\n")
 	for _, ss := range services {
 		sort.Slice(ss.ports, func(i, j int) bool {
@@ -609,28 +972,47 @@ func setupServices(svcManager *serviceManager, out *output) error {
 		})
 
 		ports := []string{}
+		endpoints[ss.name] = map[string]string{}
 		for _, p := range ss.ports {
-			ports = append(ports, fmt.Sprintf("%s: %d", p.name, p.port))
+			ports = append(ports, fmt.Sprintf("%s: %s", p.name, p.URL()))
+			endpoints[ss.name][p.name] = p.URL()
 		}
 		fmt.Printf("- %s (%s)\n", ss.name, strings.Join(ports, ", "))
 	}
 	fmt.Printf("\n")
 
+	if err := out.WriteFile("endpoints.json", endpoints); err != nil {
+		return err
+	}
+
 	fmt.Printf("All services started, press Ctrl+C to stop\n")
 	return nil
 }
 
 type output struct {
 	dst string
+
+	// store is where the artifacts are written, the dst folder if it is not set
+	store artifactStore
+}
+
+func (o *output) storage() artifactStore {
+	if o.store == nil {
+		return &localStore{root: o.dst}
+	}
+	return o.store
 }
 
 func (o *output) Exists(path string) bool {
-	_, err := os.Stat(filepath.Join(o.dst))
-	return err == nil
+	return o.storage().Exists(path)
 }
 
 func (o *output) Remove(path string) error {
-	return os.RemoveAll(filepath.Join(o.dst, path))
+	return o.storage().Remove(path)
+}
+
+func (o *output) ReadFile(path string) ([]byte, error) {
+	return o.storage().Read(path)
 }
 
 func (o *output) WriteBatch(data map[string]interface{}) error {
@@ -642,6 +1024,8 @@ func (o *output) WriteBatch(data map[string]interface{}) error {
 	return nil
 }
 
+// LogOutput creates the log of the service, which is always in the dst folder since the
+// services write to it directly
 func (o *output) LogOutput(name string) (*os.File, error) {
 	path := filepath.Join(o.dst, "logs", name+".log")
 
@@ -656,8 +1040,6 @@ func (o *output) LogOutput(name string) (*os.File, error) {
 }
 
 func (o *output) WriteFile(dst string, data interface{}) error {
-	dst = filepath.Join(o.dst, dst)
-
 	var dataRaw []byte
 	var err error
 
@@ -671,7 +1053,7 @@ func (o *output) WriteFile(dst string, data interface{}) error {
 		}
 	} else if encObj, ok := data.(encObject); ok {
 		// create a new output for this sub-object and delegate the full encoding to it
-		if err = encObj.Encode(&output{dst: dst}); err != nil {
+		if err = encObj.Encode(&output{dst: filepath.Join(o.dst, dst), store: o.storage().Sub(dst)}); err != nil {
 			return err
 		}
 		return nil
@@ -695,13 +1077,7 @@ func (o *output) WriteFile(dst string, data interface{}) error {
 		}
 	}
 
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return err
-	}
-	if err := os.WriteFile(dst, dataRaw, 0644); err != nil {
-		return err
-	}
-	return nil
+	return o.storage().Write(dst, dataRaw)
 }
 
 var secret = "secret"
@@ -753,8 +1129,13 @@ type sszObject interface {
 }
 
 type serviceManager struct {
-	out     *output
-	handles []*handle
+	out *output
+
+	// services is the list of all the services, including the ones waiting to start
+	services []*service
+
+	handlesLock sync.Mutex
+	handles     []*handle
 
 	stopping atomic.Bool
 
@@ -762,10 +1143,54 @@ type serviceManager struct {
 
 	// channel for the handles to nofify when they are shutting down
 	closeCh chan struct{}
+
+	// ctx is cancelled when the services are being stopped. The background tasks
+	// (cron jobs, artifact waits) exit when it is done.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// functions to stop the services that run inside the playground process
+	stopFns []func() error
+
+	// relay is the embedded relay, for the scenarios that inject faults in it
+	relay *mevboostrelay.MevBoostRelay
+}
+
+func newServiceManager(ctx context.Context, out *output) *serviceManager {
+	ctx, cancel := context.WithCancel(ctx)
+	return &serviceManager{out: out, handles: []*handle{}, stopping: atomic.Bool{}, wg: sync.WaitGroup{}, closeCh: make(chan struct{}, 5), ctx: ctx, cancel: cancel}
 }
 
-func newServiceManager(out *output) *serviceManager {
-	return &serviceManager{out: out, handles: []*handle{}, stopping: atomic.Bool{}, wg: sync.WaitGroup{}, closeCh: make(chan struct{}, 5)}
+// OnStop registers a function to stop a service that runs inside the playground process
+func (s *serviceManager) OnStop(fn func() error) {
+	s.stopFns = append(s.stopFns, fn)
+}
+
+// RunInProcess runs a service inside the playground process. The stop function is
+// called when the services are stopped and it must make run return.
+func (s *serviceManager) RunInProcess(name string, run func() error, stop func() error) {
+	s.OnStop(stop)
+	emitProgress(&progressEvent{Type: progressServiceStarted, Service: name})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		if err := run(); err != nil && !s.stopping.Load() {
+			logger.Error("Error running service", "service", name, "err", err)
+			s.emitError()
+		}
+	}()
+}
+
+// serveHTTP returns a function that runs the http server until it is closed
+func serveHTTP(srv *http.Server) func() error {
+	return func() error {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
 }
 
 func (s *serviceManager) emitError() {
@@ -776,67 +1201,292 @@ func (s *serviceManager) emitError() {
 }
 
 func (s *serviceManager) Run(ss *service) {
-	cmd := exec.Command(ss.args[0], ss.args[1:]...)
+	s.services = append(s.services, ss)
+
+	if res, ok := serviceResourceLimits[ss.name]; ok {
+		ss.resources = res
+	}
+	if ov, ok := serviceOverrides[ss.name]; ok {
+		ov.apply(ss)
+	}
+	if offset, ok := serviceSkews[ss.name]; ok {
+		ss.withSkew(offset)
+		logger.Info("Service runs with a clock skew", "service", ss.name, "skew", offset)
+	}
+	if spec, ok := serviceChaos[ss.name]; ok {
+		ss.WithChaos(spec)
+	}
+
+	// the job completed in a previous run does not apply to this one
+	if ss.job {
+		if err := s.out.Remove(jobArtifact(ss.name)); err != nil {
+			logger.Error("Error removing the artifact of the job", "job", ss.name, "err", err)
+		}
+	}
+
+	if len(ss.artifactDeps) == 0 {
+		s.start(ss)
+		return
+	}
+
+	logger.Info("Service waits for the artifacts", "service", ss.name, "artifacts", strings.Join(ss.artifactDeps, ", "))
+	emitProgress(&progressEvent{Type: progressServiceWaiting, Service: ss.name, Message: strings.Join(ss.artifactDeps, ", ")})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		for _, artifact := range ss.artifactDeps {
+			for !s.out.Exists(artifact) {
+				select {
+				case <-s.ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}
+		logger.Info("Artifacts found, starting the service", "service", ss.name)
+		s.start(ss)
+	}()
+}
+
+func (s *serviceManager) start(ss *service) {
+	// hold the lock while the process starts so that it is either stopped
+	// with the others or it does not start at all
+	s.handlesLock.Lock()
+	defer s.handlesLock.Unlock()
+
+	if s.stopping.Load() {
+		return
+	}
+
+	// the ports are fixed, fail with a clear error instead of the one of the service
+	for _, p := range ss.ports {
+		if err := p.Available(); err != nil {
+			logger.Error("Error running service", "service", ss.name, "err", err)
+			s.emitError()
+			return
+		}
+	}
+
+	args := ss.command()
+	cmd := exec.Command(args[0], args[1:]...)
 
 	logOutput, err := s.out.LogOutput(ss.name)
 	if err != nil {
 		// this should not happen, log it
-		fmt.Println("Error creating log output for", ss.name)
+		logger.Error("Error creating the log output", "service", ss.name, "err", err)
 		logOutput = os.Stdout
 	}
 
 	// first thing to output is the command itself
-	fmt.Fprint(logOutput, strings.Join(ss.args, " ")+"\n\n")
+	fmt.Fprint(logOutput, strings.Join(args, " ")+"\n\n")
 
 	cmd.Stdout = logOutput
 	cmd.Stderr = logOutput
+	cmd.Env = serviceEnv(ss.name, envPassthroughFlag, ss.env)
+
+	// run the service in its own process group so that the signals reach any
+	// child process it spawns and they do not receive the Ctrl+C of the terminal.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		logger.Error("Error running service", "service", ss.name, "err", err)
+		s.emitError()
+		return
+	}
+
+	h := &handle{
+		Process:   cmd,
+		Service:   ss,
+		logOutput: logOutput,
+		doneCh:    make(chan struct{}),
+	}
+
+	emitProgress(&progressEvent{Type: progressServiceStarted, Service: ss.name})
+	s.recordVersion(ss)
+
+	// the pid file is used by the other commands (i.e. pause) to find the process
+	if err := s.out.WriteFile(pidFilePath(ss.name), strconv.Itoa(cmd.Process.Pid)); err != nil {
+		logger.Error("Error writing the pid file", "service", ss.name, "err", err)
+	}
 
 	s.wg.Add(1)
 	go func() {
-		if err := cmd.Run(); err != nil {
-			if !s.stopping.Load() {
-				fmt.Printf("Error running %s: %v\n", ss.name, err)
-			}
+		err := cmd.Wait()
+		exitCode := cmd.ProcessState.ExitCode()
+
+		// attribute the failure with the errors in the log of the service
+		var snippet string
+		if err != nil && !s.stopping.Load() {
+			logger.Error("Error running service", "service", ss.name, "err", err)
+			snippet = s.reportFailure(ss.name, exitCode)
+		}
+		s.out.Remove(pidFilePath(ss.name))
+		emitProgress(&progressEvent{Type: progressServiceExited, Service: ss.name, ExitCode: &exitCode, Message: snippet})
+		close(h.doneCh)
+		if ss.job && err == nil && !s.stopping.Load() {
+			s.completeJob(ss)
+		} else {
+			s.emitError()
 		}
 		s.wg.Done()
-		s.emitError()
 	}()
 
-	s.handles = append(s.handles, &handle{
-		Process: cmd,
-		Service: ss,
-	})
+	if ss.job {
+		s.watchJob(h)
+	}
+	s.handles = append(s.handles, h)
 }
 
 type handle struct {
 	Process *exec.Cmd
 	Service *service
+
+	logOutput io.Writer
+
+	// closed when the process exits
+	doneCh chan struct{}
 }
 
 func (s *serviceManager) NotifyErrCh() <-chan struct{} {
 	return s.closeCh
 }
 
+// StopAndWait sends a SIGTERM to all the services and waits for them to exit.
+// The services that are still running after the grace period are killed.
 func (s *serviceManager) StopAndWait() {
-	s.stopping.Store(true)
+	s.handlesLock.Lock()
+	if s.stopping.Swap(true) {
+		s.handlesLock.Unlock()
+		return
+	}
+	s.handlesLock.Unlock()
+	s.cancel()
+
+	s.runPreStopHooks()
+
+	for _, fn := range s.stopFns {
+		if err := fn(); err != nil {
+			logger.Error("Error stopping service", "err", err)
+		}
+	}
 
 	for _, h := range s.handles {
-		if h.Process != nil {
-			fmt.Printf("Stopping %s\n", h.Service.name)
-			h.Process.Process.Kill()
+		select {
+		case <-h.doneCh:
+			// the process has already exited (i.e. a completed job)
+			continue
+		default:
+		}
+		logger.Info("Stopping service", "service", h.Service.name)
+		h.signal(syscall.SIGTERM)
+		// a service paused with SIGSTOP (pause, --freeze-at or chaos) only handles the
+		// SIGTERM once it is resumed
+		h.signal(syscall.SIGCONT)
+	}
+
+	timeoutCh := time.After(stopGracePeriodFlag)
+	expired := false
+	for _, h := range s.handles {
+		if !expired {
+			select {
+			case <-h.doneCh:
+				continue
+			case <-timeoutCh:
+				// kill the rest of the services right away
+				expired = true
+			}
+		}
+		select {
+		case <-h.doneCh:
+		default:
+			logger.Warn("Killing service", "service", h.Service.name)
+			h.signal(syscall.SIGKILL)
+			h.signal(syscall.SIGCONT)
 		}
 	}
 	s.wg.Wait()
 }
 
+// signal sends the signal to the whole process group of the service
+func (h *handle) signal(sig syscall.Signal) {
+	select {
+	case <-h.doneCh:
+		// the process has already exited, its process group might be reused
+	default:
+		syscall.Kill(-h.Process.Process.Pid, sig)
+	}
+}
+
+const (
+	protocolHTTP      = "http"
+	protocolWS        = "ws"
+	protocolEngineAPI = "engine-api"
+	protocolP2P       = "p2p"
+	protocolGRPC      = "grpc"
+)
+
 type port struct {
-	name string
-	port int
+	name     string
+	port     int
+	protocol string
+}
+
+// URL returns the address to connect to the port from the host. The p2p ports
+// are not urls, they are returned as host:port.
+func (p *port) URL() string {
+	switch p.protocol {
+	case protocolHTTP, protocolEngineAPI:
+		// the engine api is a JSON-RPC api over http authenticated with the jwt secret
+		return fmt.Sprintf("http://localhost:%d", p.port)
+	case protocolWS:
+		return fmt.Sprintf("ws://localhost:%d", p.port)
+	default:
+		return fmt.Sprintf("localhost:%d", p.port)
+	}
+}
+
+// Available returns an error if the port is already in use in the host (i.e. by
+// another playground or a local node). Only tcp is checked, the p2p ports listen
+// on both tcp and udp.
+func (p *port) Available() error {
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", p.port))
+	if err != nil {
+		return fmt.Errorf("port %s (%d) is already in use", p.name, p.port)
+	}
+	return l.Close()
 }
 
 type service struct {
 	name string
 	args []string
+	env  []string
+
+	// deps are the connections of the service to the ports of other services
+	deps []*dependency
+
+	// preStop are the actions to run before the service is stopped
+	preStop []*preStopHook
+
+	// artifactDeps are the artifacts that must exist in the output folder before the service starts
+	artifactDeps []string
+
+	// inProcess is set for the services that run inside the playground process
+	inProcess bool
+
+	// resources are the cpu and memory limits of the service (see WithResources)
+	resources *serviceResources
+
+	// chaos are the faults injected in the service (see WithChaos)
+	chaos *chaosSpec
+
+	// job is set for the services that run to completion (see AsJob)
+	job        bool
+	jobTimeout time.Duration
 
 	ports  []*port
 	srvMng *serviceManager
@@ -846,8 +1496,27 @@ func (s *serviceManager) NewService(name string) *service {
 	return &service{name: name, args: []string{}, srvMng: s}
 }
 
-func (s *service) WithPort(name string, portNumber int) *service {
-	s.ports = append(s.ports, &port{name: name, port: portNumber})
+func (s *service) WithPort(name string, portNumber int, protocol string) *service {
+	s.ports = append(s.ports, &port{name: name, port: portNumber, protocol: protocol})
+	return s
+}
+
+// WithDependency records that the service connects to the port of another service
+func (s *service) WithDependency(service, port, kind string) *service {
+	s.deps = append(s.deps, &dependency{service: service, port: port, kind: kind})
+	return s
+}
+
+// DependsOnArtifact delays the start of the service until the artifact exists in the output
+// folder (i.e. a file written by another service once it is running).
+func (s *service) DependsOnArtifact(name string) *service {
+	s.artifactDeps = append(s.artifactDeps, name)
+	return s
+}
+
+// WithEnv sets an environment variable for the process of the service
+func (s *service) WithEnv(key, value string) *service {
+	s.env = append(s.env, key+"="+applyTemplate(value, s.tmplVars()))
 	return s
 }
 
@@ -862,6 +1531,14 @@ func (s *service) WithArgs(args ...string) *service {
 	return s
 }
 
+// command returns the command line of the service with the wrapper of its limits
+func (s *service) command() []string {
+	if s.resources != nil {
+		return s.resources.wrapArgs(s.args)
+	}
+	return s.args
+}
+
 func (s *service) tmplVars() map[string]interface{} {
 	tmplVars := map[string]interface{}{
 		"Dir": s.srvMng.out.dst,
@@ -960,6 +1637,41 @@ func isByteSlice(t reflect.Type) bool {
 	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
 }
 
+// checkpointSyncURLs are the default checkpoint sync endpoints for the public networks
+var checkpointSyncURLs = map[string]string{
+	"sepolia": "https://checkpoint-sync.sepolia.ethpandaops.io",
+	"holesky": "https://checkpoint-sync.holesky.ethpandaops.io",
+	"hoodi":   "https://checkpoint-sync.hoodi.ethpandaops.io",
+}
+
+var prefundedBalanceFlag string
+
+// defaultPrefundedBalance is the balance in wei of each prefunded account without --prefunded-balance
+var defaultPrefundedBalance, _ = new(big.Int).SetString("10000000000000000000000", 16)
+
+// parseBalance parses a balance in wei, or in ether with the eth suffix (i.e. 1000eth)
+func parseBalance(s string) (*big.Int, error) {
+	amount, unit := s, big.NewInt(1)
+	if eth, ok := strings.CutSuffix(s, "eth"); ok {
+		amount, unit = eth, big.NewInt(1e18)
+	}
+	balance, ok := new(big.Int).SetString(amount, 10)
+	if !ok || balance.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid balance '%s', expected a positive number of wei or <n>eth", s)
+	}
+	return balance.Mul(balance, unit), nil
+}
+
+// prefundedBalance returns the balance in wei of each prefunded account
+func prefundedBalance() *big.Int {
+	if prefundedBalanceFlag == "" {
+		return new(big.Int).Set(defaultPrefundedBalance)
+	}
+	// the flag is validated when the session starts
+	balance, _ := parseBalance(prefundedBalanceFlag)
+	return balance
+}
+
 var prefundedAccounts = []string{
 	"0xac0974bec39a17e36ba4a6b4d238ff944bacb478cbed5efcae784d7bf4f2ff80",
 	"0x59c6995e998f97a5a0044966f0945389dc9e86dae88c7a8412f4603b6b78690d",
@@ -990,19 +1702,14 @@ func getHomeDir() (string, error) {
 	return customHomeDir, nil
 }
 
-func watchProposerPayloads() {
-	// This is not the most efficient solution since we are querying the endpoint for the full list of payloads
-	// every 2 seconds. It should be fine for the kind of workloads expected to run.
-
+// newProposerPayloadsWatcher returns a job that logs the new payloads delivered by the relay
+func newProposerPayloadsWatcher() func(ctx context.Context) error {
 	lastSlot := uint64(0)
 
-	for {
-		time.Sleep(2 * time.Second)
-
-		vals, err := getProposerPayloadDelivered()
+	return func(ctx context.Context) error {
+		vals, err := getProposerPayloadDelivered(ctx)
 		if err != nil {
-			fmt.Println("Error getting proposer payloads:", err)
-			continue
+			return fmt.Errorf("error getting proposer payloads: %w", err)
 		}
 
 		for _, val := range vals {
@@ -1010,14 +1717,19 @@ func watchProposerPayloads() {
 				continue
 			}
 
-			fmt.Printf("Block Proposed: Slot: %d, Builder: %s, Block: %d\n", val.Slot, val.BuilderPubkey, val.BlockNumber)
+			logger.Info("Block proposed", "slot", val.Slot, "builder", val.BuilderPubkey, "block", val.BlockNumber)
 			lastSlot = val.Slot
 		}
+		return nil
 	}
 }
 
-func getProposerPayloadDelivered() ([]*mevRCommon.BidTraceV2JSON, error) {
-	resp, err := http.Get("http://localhost:5555/relay/v1/data/bidtraces/proposer_payload_delivered")
+func getProposerPayloadDelivered(ctx context.Context) ([]*mevRCommon.BidTraceV2JSON, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost:5555/relay/v1/data/bidtraces/proposer_payload_delivered", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
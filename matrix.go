@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var matrixConfigFlag string
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Run the test scenarios across a matrix of client versions",
+	Long:  `Run the playground and the test scenarios for every combination of the client versions in the config file and report the result of each combination`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMatrix(cmd.Context())
+	},
+}
+
+// matrixConfig is the list of versions to test for each client. If the list
+// of a client is empty the default version is used.
+type matrixConfig struct {
+	Reth       []string `yaml:"reth"`
+	Lighthouse []string `yaml:"lighthouse"`
+}
+
+type matrixCell struct {
+	reth       string
+	lighthouse string
+}
+
+func (m *matrixCell) String() string {
+	version := func(v string) string {
+		if v == "" {
+			return "default"
+		}
+		return v
+	}
+	return fmt.Sprintf("reth=%s,lighthouse=%s", version(m.reth), version(m.lighthouse))
+}
+
+type matrixCellResult struct {
+	cell     *matrixCell
+	duration time.Duration
+	results  []*scenarioResult
+	err      error
+}
+
+func (m *matrixCellResult) failed() bool {
+	return m.err != nil || numFailed(m.results) != 0
+}
+
+func loadMatrixConfig(path string) (*matrixConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config matrixConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to decode matrix config: %w", err)
+	}
+	return &config, nil
+}
+
+func (m *matrixConfig) Cells() []*matrixCell {
+	orDefault := func(versions []string) []string {
+		if len(versions) == 0 {
+			return []string{""}
+		}
+		return versions
+	}
+
+	cells := []*matrixCell{}
+	for _, reth := range orDefault(m.Reth) {
+		for _, lighthouse := range orDefault(m.Lighthouse) {
+			cells = append(cells, &matrixCell{reth: reth, lighthouse: lighthouse})
+		}
+	}
+	return cells
+}
+
+func runMatrix(ctx context.Context) error {
+	if matrixConfigFlag == "" {
+		return fmt.Errorf("--config is required")
+	}
+	if useBinPathFlag {
+		return fmt.Errorf("--use-bin-path cannot be used with the matrix, the versions are downloaded")
+	}
+	config, err := loadMatrixConfig(matrixConfigFlag)
+	if err != nil {
+		return err
+	}
+
+	// all the cells use the same output folder to reuse the genesis of the previous cell.
+	// The binaries of each version are cached after the first download.
+	cellResults := []*matrixCellResult{}
+	for _, cell := range config.Cells() {
+		fmt.Printf("\nRunning matrix cell %s\n==================\n", cell)
+
+		rethVersionFlag = cell.reth
+		lighthouseVersionFlag = cell.lighthouse
+
+		now := time.Now()
+		results, err := runSessionScenarios(ctx)
+		if err != nil {
+			fmt.Printf("Failed to start the playground: %v\n", err)
+		}
+		cellResults = append(cellResults, &matrixCellResult{
+			cell:     cell,
+			duration: time.Since(now),
+			results:  results,
+			err:      err,
+		})
+
+		if ctx.Err() != nil {
+			fmt.Println("Matrix interrupted, skipping the remaining cells")
+			break
+		}
+	}
+
+	// print the summary
+	fmt.Printf("\nMatrix summary:\n==================\n")
+	failed := 0
+	for _, res := range cellResults {
+		status := "PASS"
+		if res.failed() {
+			status = "FAIL"
+			failed++
+		}
+
+		details := []string{}
+		if res.err != nil {
+			details = append(details, res.err.Error())
+		}
+		for _, r := range res.results {
+			if r.err != nil {
+				details = append(details, r.scenario.name)
+			}
+		}
+		line := fmt.Sprintf("- %s %s (%s)", status, res.cell, res.duration.Round(time.Second))
+		if len(details) != 0 {
+			line += ": " + strings.Join(details, ", ")
+		}
+		fmt.Println(line)
+	}
+
+	if junitFlag != "" {
+		suites := []*junitTestSuite{}
+		for _, res := range cellResults {
+			suite := newJUnitTestSuite(res.cell.String(), res.results)
+			if res.err != nil {
+				// the playground did not start, report it as a failed test case
+				suite.Cases = append(suite.Cases, &junitTestCase{
+					Name:      "startup",
+					ClassName: suite.Name,
+					Time:      fmt.Sprintf("%.3f", res.duration.Seconds()),
+					Failure:   &junitFailure{Message: res.err.Error()},
+				})
+				suite.Tests++
+				suite.Failures++
+			}
+			suites = append(suites, suite)
+		}
+		if err := writeJUnitReport(junitFlag, suites...); err != nil {
+			return err
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d matrix cells failed", failed, len(cellResults))
+	}
+	return nil
+}
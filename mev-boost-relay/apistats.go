@@ -0,0 +1,256 @@
+package mevboostrelay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the buckets of the latency histograms
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// endpointTemplates are the api paths with parameters. The requests to them are grouped
+// under the same endpoint regardless of the values of the parameters.
+var endpointTemplates = []struct {
+	prefix   string
+	template string
+}{
+	{"/eth/v1/builder/header/", "/eth/v1/builder/header/{slot}/{parent_hash}/{pubkey}"},
+	{"/internal/v1/builder/collateral/", "/internal/v1/builder/collateral/{pubkey}"},
+	{"/internal/v1/builder/", "/internal/v1/builder/{pubkey}"},
+}
+
+// EndpointStats is the summary of the requests to an endpoint of the relay api
+type EndpointStats struct {
+	Endpoint  string             `json:"endpoint"`
+	Count     uint64             `json:"count"`
+	Errors    uint64             `json:"errors"`
+	P50       float64            `json:"p50_ms"`
+	P90       float64            `json:"p90_ms"`
+	P99       float64            `json:"p99_ms"`
+	Max       float64            `json:"max_ms"`
+	Histogram []*HistogramBucket `json:"histogram"`
+}
+
+// HistogramBucket is the number of requests with a latency up to Le
+type HistogramBucket struct {
+	Le    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// apiRequest is an entry of the request log
+type apiRequest struct {
+	Time     time.Time `json:"time"`
+	Endpoint string    `json:"endpoint"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// maxLatencySamples is how many of the last latencies of each endpoint are kept for the
+// percentiles. The count, the errors, the max and the histogram cover every request.
+const maxLatencySamples = 10000
+
+type endpointLatencies struct {
+	count   uint64
+	errors  uint64
+	max     time.Duration
+	buckets []uint64 // one for each of latencyBuckets and the last one for +Inf
+
+	// samples is a ring buffer with the last latencies, next is where the next one goes
+	samples []time.Duration
+	next    int
+}
+
+func newEndpointLatencies() *endpointLatencies {
+	return &endpointLatencies{buckets: make([]uint64, len(latencyBuckets)+1)}
+}
+
+func (e *endpointLatencies) add(latency time.Duration) {
+	e.count++
+	e.max = max(e.max, latency)
+	e.buckets[sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })]++
+
+	if len(e.samples) < maxLatencySamples {
+		e.samples = append(e.samples, latency)
+	} else {
+		e.samples[e.next] = latency
+		e.next = (e.next + 1) % maxLatencySamples
+	}
+}
+
+// apiStats records the latency of the requests to each endpoint of the api and,
+// if a log is set, writes every request to it.
+type apiStats struct {
+	lock      sync.Mutex
+	endpoints map[string]*endpointLatencies
+	log       io.Writer
+}
+
+func newAPIStats(log io.Writer) *apiStats {
+	return &apiStats{endpoints: map[string]*endpointLatencies{}, log: log}
+}
+
+// Middleware wraps the handler to record its requests
+func (a *apiStats) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rw, r)
+		latency := time.Since(start)
+
+		a.record(&apiRequest{
+			Time:     start,
+			Endpoint: r.Method + " " + endpointName(r.URL.Path),
+			Path:     r.URL.RequestURI(),
+			Status:   rw.status,
+			Duration: toMs(latency),
+		}, latency)
+	})
+}
+
+func (a *apiStats) record(req *apiRequest, latency time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	e, ok := a.endpoints[req.Endpoint]
+	if !ok {
+		e = newEndpointLatencies()
+		a.endpoints[req.Endpoint] = e
+	}
+	e.add(latency)
+	if req.Status >= http.StatusBadRequest {
+		e.errors++
+	}
+
+	if a.log != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return
+		}
+		a.log.Write(append(data, '\n'))
+	}
+}
+
+// Summary returns the stats of each endpoint sorted by endpoint. The percentiles are
+// of the last maxLatencySamples requests of the endpoint.
+func (a *apiStats) Summary() []*EndpointStats {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	summary := []*EndpointStats{}
+	for endpoint, e := range a.endpoints {
+		latencies := make([]time.Duration, len(e.samples))
+		copy(latencies, e.samples)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		stats := &EndpointStats{
+			Endpoint: endpoint,
+			Count:    e.count,
+			Errors:   e.errors,
+			P50:      toMs(percentile(latencies, 0.5)),
+			P90:      toMs(percentile(latencies, 0.9)),
+			P99:      toMs(percentile(latencies, 0.99)),
+			Max:      toMs(e.max),
+		}
+		for i, count := range e.buckets {
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = latencyBuckets[i].String()
+			}
+			stats.Histogram = append(stats.Histogram, &HistogramBucket{Le: le, Count: count})
+		}
+
+		summary = append(summary, stats)
+	}
+	sort.Slice(summary, func(i, j int) bool { return summary[i].Endpoint < summary[j].Endpoint })
+	return summary
+}
+
+// APIStats returns the latency stats of each endpoint of the api
+func (m *MevBoostRelay) APIStats() []*EndpointStats {
+	return m.apiStats.Summary()
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	idx := int(math.Ceil(p*float64(len(latencies)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return latencies[idx]
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func endpointName(path string) string {
+	for _, e := range endpointTemplates {
+		if strings.HasPrefix(path, e.prefix) {
+			return e.template
+		}
+	}
+	return path
+}
+
+// statusResponseWriter records the status code of the response
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// newAPIStatsServer serves the api on the public address and forwards the requests to the
+// api server listening on the internal address. The api of the relay does not expose its
+// router, so the requests are recorded (and the faults injected) in this proxy.
+func newAPIStatsServer(addr string, internalAddr func() string, stats *apiStats, faults *faults) *http.Server {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			// the internal address changes if the api server is restarted on another port
+			r.SetURL(&url.URL{Scheme: "http", Host: internalAddr()})
+			r.SetXForwarded()
+		},
+	}
+	return &http.Server{
+		Addr:    addr,
+		Handler: stats.Middleware(faults.Middleware(proxy)),
+	}
+}
+
+// freeLocalAddr returns a local address with a port that is not in use
+func freeLocalAddr() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
@@ -0,0 +1,173 @@
+package mevboostrelay
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/gorilla/websocket"
+)
+
+//go:embed auction.html
+var auctionPage []byte
+
+const (
+	auctionEventBid       = "bid"
+	auctionEventDelivered = "delivered"
+)
+
+// AuctionEvent is a bid submitted by a builder or a payload delivered to a proposer
+type AuctionEvent struct {
+	Type          string `json:"type"`
+	Slot          uint64 `json:"slot"`
+	BlockNumber   uint64 `json:"block_number"`
+	BlockHash     string `json:"block_hash"`
+	BuilderPubkey string `json:"builder_pubkey"`
+	Value         string `json:"value"`
+	NumTx         uint64 `json:"num_tx"`
+	Timestamp     int64  `json:"timestamp_ms"`
+
+	// Error is the simulation error of the bid
+	Error string `json:"error,omitempty"`
+}
+
+// bidStream broadcasts the auction events to the subscribers
+type bidStream struct {
+	lock sync.Mutex
+	subs map[chan *AuctionEvent]struct{}
+}
+
+func newBidStream() *bidStream {
+	return &bidStream{subs: map[chan *AuctionEvent]struct{}{}}
+}
+
+func (b *bidStream) Subscribe() (chan *AuctionEvent, func()) {
+	ch := make(chan *AuctionEvent, 128)
+
+	b.lock.Lock()
+	b.subs[ch] = struct{}{}
+	b.lock.Unlock()
+
+	cancel := func() {
+		b.lock.Lock()
+		delete(b.subs, ch)
+		b.lock.Unlock()
+	}
+	return ch, cancel
+}
+
+func (b *bidStream) publish(event *AuctionEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+			// drop the event if the subscriber is too slow
+		}
+	}
+}
+
+func (b *bidStream) publishBid(payload *common.VersionedSubmitBlockRequest, requestError, validationError error, receivedAt time.Time) {
+	bidTrace, err := payload.BidTrace()
+	if err != nil {
+		return
+	}
+	event := &AuctionEvent{
+		Type:          auctionEventBid,
+		Slot:          bidTrace.Slot,
+		BlockHash:     bidTrace.BlockHash.String(),
+		BuilderPubkey: bidTrace.BuilderPubkey.String(),
+		Value:         bidTrace.Value.ToBig().String(),
+		Timestamp:     receivedAt.UnixMilli(),
+	}
+	if num, err := payload.BlockNumber(); err == nil {
+		event.BlockNumber = num
+	}
+	if txs, err := payload.Transactions(); err == nil {
+		event.NumTx = uint64(len(txs))
+	}
+	if requestError != nil {
+		event.Error = requestError.Error()
+	} else if validationError != nil {
+		event.Error = validationError.Error()
+	}
+	b.publish(event)
+}
+
+func (b *bidStream) publishDelivered(bidTrace *common.BidTraceV2WithBlobFields, signedAt time.Time) {
+	b.publish(&AuctionEvent{
+		Type:          auctionEventDelivered,
+		Slot:          bidTrace.Slot,
+		BlockNumber:   bidTrace.BlockNumber,
+		BlockHash:     bidTrace.BlockHash.String(),
+		BuilderPubkey: bidTrace.BuilderPubkey.String(),
+		Value:         bidTrace.Value.ToBig().String(),
+		NumTx:         bidTrace.NumTx,
+		Timestamp:     signedAt.UnixMilli(),
+	})
+}
+
+var upgrader = websocket.Upgrader{
+	// the page can be served from other origins (i.e. a custom dashboard)
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleWebsocket streams the auction events to the websocket client as json
+func (b *bidStream) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ch, cancel := b.Subscribe()
+	defer cancel()
+
+	// read the messages of the client only to detect when it disconnects
+	closeCh := make(chan struct{})
+	go func() {
+		defer close(closeCh)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event := <-ch:
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-closeCh:
+			return
+		}
+	}
+}
+
+func newBidStreamServer(port uint64, stream *bidStream) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", stream.handleWebsocket)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(auctionPage)
+	})
+
+	return &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Handler: mux,
+	}
+}
+
+func shutdownServer(srv *http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
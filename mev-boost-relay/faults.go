@@ -0,0 +1,97 @@
+package mevboostrelay
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of the faults of the getHeader requests of a slot
+const (
+	// FaultError makes getHeader fail with an internal error
+	FaultError = "error"
+
+	// FaultLateHeader delays the response of getHeader, i.e. past the timeout of the
+	// beacon node for the header
+	FaultLateHeader = "late-header"
+)
+
+// Fault is an error injected in the getHeader requests of a slot to test the fallback of
+// the proposer to a local block
+type Fault struct {
+	Kind string
+
+	// Delay is the delay of the response with FaultLateHeader
+	Delay time.Duration
+}
+
+// faults are the faults injected by slot
+type faults struct {
+	lock  sync.Mutex
+	slots map[uint64]*Fault
+}
+
+func newFaults() *faults {
+	return &faults{slots: map[uint64]*Fault{}}
+}
+
+func (f *faults) get(slot uint64) *Fault {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.slots[slot]
+}
+
+// Middleware wraps the handler to inject the faults in the getHeader requests
+func (f *faults) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rest, ok := strings.CutPrefix(r.URL.Path, "/eth/v1/builder/header/")
+		if !ok || r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		slotStr, _, _ := strings.Cut(rest, "/")
+		slot, err := strconv.ParseUint(slotStr, 10, 64)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fault := f.get(slot)
+		if fault == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch fault.Kind {
+		case FaultError:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, `{"code":%d,"message":"fault injected by the playground"}`, http.StatusInternalServerError)
+		case FaultLateHeader:
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(fault.Delay):
+			}
+			next.ServeHTTP(w, r)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// InjectFault makes the getHeader requests of the slot fail with the fault
+func (m *MevBoostRelay) InjectFault(slot uint64, fault *Fault) error {
+	if fault.Kind != FaultError && fault.Kind != FaultLateHeader {
+		return fmt.Errorf("unknown fault '%s'", fault.Kind)
+	}
+	m.faults.lock.Lock()
+	defer m.faults.lock.Unlock()
+
+	m.faults.slots[slot] = fault
+	m.log.WithField("slot", slot).Infof("Injected fault %s in getHeader", fault.Kind)
+	return nil
+}
@@ -3,6 +3,7 @@ package mevboostrelay
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
@@ -34,7 +36,20 @@ type Config struct {
 	BeaconClientAddr string
 	LogOutput        io.Writer
 
+	// BidStreamPort serves the websocket with the auction events and a page to
+	// watch them. It is disabled if set to 0.
+	BidStreamPort uint64
+
 	UseRethForValidation bool
+
+	// ValidationServerAddr is the url of a node with the flashbots_validateBuilderSubmission
+	// endpoints (i.e. reth or geth with the flashbots api) that validates the builder
+	// submissions. It takes precedence over UseRethForValidation.
+	ValidationServerAddr string
+
+	// RequestLog receives every request to the api (one JSON object per line). The
+	// latency stats of the api are recorded even if it is not set.
+	RequestLog io.Writer
 }
 
 func DefaultConfig() *Config {
@@ -43,6 +58,7 @@ func DefaultConfig() *Config {
 		ApiListenPort:        5555,
 		ApiSecretKey:         defaultSecretKey,
 		BeaconClientAddr:     "http://localhost:3500",
+		BidStreamPort:        5556,
 		LogOutput:            os.Stdout,
 		UseRethForValidation: false,
 	}
@@ -50,8 +66,21 @@ func DefaultConfig() *Config {
 
 type MevBoostRelay struct {
 	log            *logrus.Entry
-	apiSrv         *api.RelayAPI
 	housekeeperSrv *housekeeper.Housekeeper
+
+	// apiSrv is replaced by a new one if its internal port is taken before it binds it
+	apiLock sync.Mutex
+	apiSrv  *api.RelayAPI
+	apiOpts api.RelayAPIOpts
+
+	bidStreamSrv *http.Server
+	apiStatsSrv  *http.Server
+
+	readiness  *readiness
+	validation *validationStats
+	auction    *auctionLog
+	faults     *faults
+	apiStats   *apiStats
 }
 
 func New(config *Config) (*MevBoostRelay, error) {
@@ -100,7 +129,11 @@ func New(config *Config) (*MevBoostRelay, error) {
 	}
 
 	// create the mockDB
-	pqDB := newInmemoryDB()
+	bidStream := newBidStream()
+	readiness := newReadiness()
+	validation := newValidationStats()
+	auction := newAuctionLog()
+	pqDB := newInmemoryDB(log, bidStream, readiness, validation, auction)
 
 	// datastore
 	ds, err := datastore.NewDatastore(redis, nil, pqDB)
@@ -123,7 +156,10 @@ func New(config *Config) (*MevBoostRelay, error) {
 	housekeeperSrv := housekeeper.NewHousekeeper(housekeeperOpts)
 
 	var blockSimURL string
-	if config.UseRethForValidation {
+	if config.ValidationServerAddr != "" {
+		log.Info("Using ", config.ValidationServerAddr, " for block validation")
+		blockSimURL = config.ValidationServerAddr
+	} else if config.UseRethForValidation {
 		log.Info("Using reth for block validation")
 		blockSimURL = "http://localhost:8545"
 	} else {
@@ -149,7 +185,6 @@ func New(config *Config) (*MevBoostRelay, error) {
 
 	apiOpts := api.RelayAPIOpts{
 		Log:             log.WithField("service", "api"),
-		ListenAddr:      fmt.Sprintf("%s:%d", config.ApiListenAddr, config.ApiListenPort),
 		BeaconClient:    bClient,
 		Datastore:       ds,
 		Redis:           redis,
@@ -161,20 +196,29 @@ func New(config *Config) (*MevBoostRelay, error) {
 		BlockBuilderAPI: true,
 		DataAPI:         true,
 	}
-	apiSrv, err := api.NewRelayAPI(apiOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create service")
-	}
 
-	return &MevBoostRelay{
+	relay := &MevBoostRelay{
 		log:            log,
-		apiSrv:         apiSrv,
+		apiOpts:        apiOpts,
 		housekeeperSrv: housekeeperSrv,
-	}, nil
+		readiness:      readiness,
+		validation:     validation,
+		auction:        auction,
+		faults:         newFaults(),
+		apiStats:       newAPIStats(config.RequestLog),
+	}
+	if err := relay.newAPIServer(); err != nil {
+		return nil, err
+	}
+	relay.apiStatsSrv = newAPIStatsServer(fmt.Sprintf("%s:%d", config.ApiListenAddr, config.ApiListenPort), relay.apiAddr, relay.apiStats, relay.faults)
+	if config.BidStreamPort != 0 {
+		relay.bidStreamSrv = newBidStreamServer(config.BidStreamPort, bidStream)
+	}
+	return relay, nil
 }
 
 func (m *MevBoostRelay) Start() error {
-	errChan := make(chan error, 2)
+	errChan := make(chan error, 4)
 
 	m.log.Info("Starting housekeeper service...")
 	go func() {
@@ -185,26 +229,127 @@ func (m *MevBoostRelay) Start() error {
 
 	m.log.Info("Starting API service...")
 	go func() {
-		err := m.apiSrv.StartServer()
+		err := m.startAPIServer()
 		m.log.WithError(err).Error("API service stopped")
 		errChan <- err
 	}()
 
 	go func() {
-		// We only require to do this at startup once, because otherwise we will
-		// just keep with the normal workflow of the mev-boost-relay.
-		<-m.apiSrv.ValidatorUpdateCh()
-
-		m.log.Info("Forcing validator registration at startup")
-
-		m.housekeeperSrv.UpdateProposerDutiesWithoutChecks(0)
-		m.apiSrv.UpdateProposerDutiesWithoutChecks(0)
+		err := m.apiStatsSrv.ListenAndServe()
+		if err == http.ErrServerClosed {
+			return
+		}
+		m.log.WithError(err).Error("API proxy stopped")
+		errChan <- err
 	}()
 
+	if m.bidStreamSrv != nil {
+		m.log.Info("Starting bid stream service...")
+		go func() {
+			err := m.bidStreamSrv.ListenAndServe()
+			if err == http.ErrServerClosed {
+				return
+			}
+			m.log.WithError(err).Error("Bid stream service stopped")
+			errChan <- err
+		}()
+	}
+
 	err := <-errChan
 	return err
 }
 
+// maxAPIBindAttempts is how many internal ports the api server tries. The port is free when
+// the api server is created, but the api only binds it after it syncs with the beacon node
+// at startup, so another process can take it in between.
+const maxAPIBindAttempts = 5
+
+// newAPIServer creates the api server of the relay on a free internal address, behind the
+// proxy that records the requests
+func (m *MevBoostRelay) newAPIServer() error {
+	addr, err := freeLocalAddr()
+	if err != nil {
+		return err
+	}
+
+	m.apiLock.Lock()
+	defer m.apiLock.Unlock()
+
+	m.apiOpts.ListenAddr = addr
+	apiSrv, err := api.NewRelayAPI(m.apiOpts)
+	if err != nil {
+		return fmt.Errorf("failed to create service")
+	}
+	m.apiSrv = apiSrv
+	return nil
+}
+
+// startAPIServer runs the api server and, if its internal port was taken before it could
+// bind it, creates and runs a new one on another port. The proxy forwards the requests to
+// the last one.
+func (m *MevBoostRelay) startAPIServer() error {
+	for attempt := 1; ; attempt++ {
+		apiSrv := m.relayAPI()
+
+		done := make(chan struct{})
+		go m.forceValidatorRegistration(apiSrv, done)
+
+		err := apiSrv.StartServer()
+		close(done)
+		if !errors.Is(err, syscall.EADDRINUSE) || attempt == maxAPIBindAttempts {
+			return err
+		}
+
+		m.log.WithError(err).Warn("API port was taken, restarting the API service on another port")
+		if err := m.newAPIServer(); err != nil {
+			return err
+		}
+	}
+}
+
+// forceValidatorRegistration updates the proposer duties after the first validator
+// registration to the api server, or returns when done is closed
+func (m *MevBoostRelay) forceValidatorRegistration(apiSrv *api.RelayAPI, done chan struct{}) {
+	// We only require to do this at startup once, because otherwise we will
+	// just keep with the normal workflow of the mev-boost-relay.
+	select {
+	case <-apiSrv.ValidatorUpdateCh():
+	case <-done:
+		return
+	}
+
+	m.log.Info("Forcing validator registration at startup")
+
+	m.housekeeperSrv.UpdateProposerDutiesWithoutChecks(0)
+	apiSrv.UpdateProposerDutiesWithoutChecks(0)
+}
+
+func (m *MevBoostRelay) relayAPI() *api.RelayAPI {
+	m.apiLock.Lock()
+	defer m.apiLock.Unlock()
+	return m.apiSrv
+}
+
+// apiAddr returns the internal address of the api server
+func (m *MevBoostRelay) apiAddr() string {
+	m.apiLock.Lock()
+	defer m.apiLock.Unlock()
+	return m.apiOpts.ListenAddr
+}
+
+// Stop stops the api server of the relay
+func (m *MevBoostRelay) Stop() error {
+	if m.bidStreamSrv != nil {
+		if err := shutdownServer(m.bidStreamSrv); err != nil {
+			return err
+		}
+	}
+	if err := shutdownServer(m.apiStatsSrv); err != nil {
+		return err
+	}
+	return m.relayAPI().StopServer()
+}
+
 func generateEthNetworkDetails(spec *Spec, info *beaconclient.GetGenesisResponse) (*common.EthNetworkDetails, error) {
 	envs := map[string]string{
 		"GENESIS_FORK_VERSION":    info.Data.GenesisForkVersion,
@@ -275,6 +420,20 @@ func startMockBlockValidationServiceServer() (string, error) {
 type inmemoryDB struct {
 	*database.MockDB
 
+	// events of the builder submissions and the delivered payloads
+	bidStream *bidStream
+
+	// stages reached by the relay
+	readiness *readiness
+
+	log *logrus.Entry
+
+	// results of the validation of the builder submissions
+	validation *validationStats
+
+	// bids of each builder per slot and the winning bids
+	auction *auctionLog
+
 	validatorRegistryEntriesLock sync.Mutex
 	validatorRegistryEntries     map[string]*database.ValidatorRegistrationEntry
 
@@ -282,9 +441,14 @@ type inmemoryDB struct {
 	deliveredPayloads     []*database.DeliveredPayloadEntry
 }
 
-func newInmemoryDB() *inmemoryDB {
+func newInmemoryDB(log *logrus.Entry, bidStream *bidStream, readiness *readiness, validation *validationStats, auction *auctionLog) *inmemoryDB {
 	return &inmemoryDB{
 		MockDB:                   &database.MockDB{},
+		bidStream:                bidStream,
+		readiness:                readiness,
+		log:                      log,
+		validation:               validation,
+		auction:                  auction,
 		validatorRegistryEntries: make(map[string]*database.ValidatorRegistrationEntry),
 		deliveredPayloads:        make([]*database.DeliveredPayloadEntry, 0),
 	}
@@ -301,6 +465,7 @@ func (i *inmemoryDB) SaveValidatorRegistration(entry database.ValidatorRegistrat
 	defer i.validatorRegistryEntriesLock.Unlock()
 
 	i.validatorRegistryEntries[entry.Pubkey] = &entry
+	i.readiness.reach(StageValidatorsRegistered)
 	return nil
 }
 
@@ -340,6 +505,25 @@ func (i *inmemoryDB) GetValidatorRegistrationsForPubkeys(pubkeys []string) ([]*d
 	return entries, nil
 }
 
+// -- endpoints for the builder submissions ---
+
+func (i *inmemoryDB) SaveBuilderBlockSubmission(payload *common.VersionedSubmitBlockRequest, requestError, validationError error, receivedAt, eligibleAt time.Time, wasSimulated, saveExecPayload bool, profile common.Profile, optimisticSubmission bool) (*database.BuilderBlockSubmissionEntry, error) {
+	i.bidStream.publishBid(payload, requestError, validationError, receivedAt)
+	if wasSimulated {
+		i.validation.record(validationError)
+		if validationError != nil {
+			i.log.WithError(validationError).WithFields(submissionFields(payload)).Warn("Block validation failed")
+		}
+	}
+	if requestError == nil && validationError == nil {
+		i.readiness.reach(StageFirstBid)
+		if bidTrace, err := payload.BidTrace(); err == nil {
+			i.auction.recordBid(bidTrace.Slot, bidTrace.BuilderPubkey.String())
+		}
+	}
+	return i.MockDB.SaveBuilderBlockSubmission(payload, requestError, validationError, receivedAt, eligibleAt, wasSimulated, saveExecPayload, profile, optimisticSubmission)
+}
+
 // -- endpoints for the delivered payloads ---
 
 func (i *inmemoryDB) SaveDeliveredPayload(bidTrace *common.BidTraceV2WithBlobFields, signedBlindedBeaconBlock *common.VersionedSignedBlindedBeaconBlock, signedAt time.Time, publishMs uint64) error {
@@ -380,6 +564,17 @@ func (i *inmemoryDB) SaveDeliveredPayload(bidTrace *common.BidTraceV2WithBlobFie
 	}
 
 	i.deliveredPayloads = append(i.deliveredPayloads, &deliveredPayloadEntry)
+	i.bidStream.publishDelivered(bidTrace, signedAt)
+
+	winner := i.auction.recordWinner(bidTrace)
+	i.log.WithFields(logrus.Fields{
+		"slot":          winner.Slot,
+		"blockHash":     winner.BlockHash,
+		"builderPubkey": winner.BuilderPubkey,
+		"value":         winner.Value,
+		"bids":          winner.Bids,
+		"builders":      winner.Builders,
+	}).Info("Winning bid")
 	return nil
 }
 
@@ -0,0 +1,50 @@
+package mevboostrelay
+
+import (
+	"sync"
+	"time"
+)
+
+// Readiness stages of the relay once its api is up
+const (
+	// StageValidatorsRegistered is reached with the first validator registration
+	StageValidatorsRegistered = "validators-registered"
+
+	// StageFirstBid is reached with the first valid bid submitted by a builder
+	StageFirstBid = "first-bid"
+)
+
+// readiness records the time at which each stage is reached
+type readiness struct {
+	lock   sync.Mutex
+	stages map[string]time.Time
+}
+
+func newReadiness() *readiness {
+	return &readiness{stages: map[string]time.Time{}}
+}
+
+func (r *readiness) reach(stage string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.stages[stage]; !ok {
+		r.stages[stage] = time.Now()
+	}
+}
+
+func (r *readiness) reached() map[string]time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stages := make(map[string]time.Time, len(r.stages))
+	for stage, t := range r.stages {
+		stages[stage] = t
+	}
+	return stages
+}
+
+// Stages returns the readiness stages reached by the relay and when they were reached
+func (m *MevBoostRelay) Stages() map[string]time.Time {
+	return m.readiness.reached()
+}
@@ -0,0 +1,70 @@
+package mevboostrelay
+
+import (
+	"sync"
+
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/sirupsen/logrus"
+)
+
+// ValidationStats is the number of builder submissions validated by the validation
+// server of the relay and the ones it rejected
+type ValidationStats struct {
+	Validated uint64 `json:"validated"`
+	Failed    uint64 `json:"failed"`
+
+	// Errors is the number of failed validations by error
+	Errors map[string]uint64 `json:"errors,omitempty"`
+}
+
+// validationStats records the results of the validation of the builder submissions
+type validationStats struct {
+	lock  sync.Mutex
+	stats ValidationStats
+}
+
+func newValidationStats() *validationStats {
+	return &validationStats{stats: ValidationStats{Errors: map[string]uint64{}}}
+}
+
+func (v *validationStats) record(validationError error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.stats.Validated++
+	if validationError != nil {
+		v.stats.Failed++
+		v.stats.Errors[validationError.Error()]++
+	}
+}
+
+func (v *validationStats) summary() *ValidationStats {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	stats := v.stats
+	stats.Errors = make(map[string]uint64, len(v.stats.Errors))
+	for err, count := range v.stats.Errors {
+		stats.Errors[err] = count
+	}
+	return &stats
+}
+
+// ValidationStats returns the results of the validation of the builder submissions
+func (m *MevBoostRelay) ValidationStats() *ValidationStats {
+	return m.validation.summary()
+}
+
+// submissionFields are the fields of the log of a builder submission
+func submissionFields(payload *common.VersionedSubmitBlockRequest) logrus.Fields {
+	bidTrace, err := payload.BidTrace()
+	if err != nil {
+		return logrus.Fields{}
+	}
+	return logrus.Fields{
+		"slot":          bidTrace.Slot,
+		"blockHash":     bidTrace.BlockHash.String(),
+		"builderPubkey": bidTrace.BuilderPubkey.String(),
+		"value":         bidTrace.Value.ToBig().String(),
+	}
+}
@@ -0,0 +1,81 @@
+package mevboostrelay
+
+import (
+	"sync"
+
+	"github.com/flashbots/mev-boost-relay/common"
+)
+
+// WinningBid is the bid delivered to the proposer of a slot and the competition for it
+type WinningBid struct {
+	Slot          uint64 `json:"slot"`
+	BlockHash     string `json:"block_hash"`
+	BuilderPubkey string `json:"builder_pubkey"`
+	Value         string `json:"value"`
+
+	// Bids is the number of valid bids for the slot and Builders the number of builders
+	// that sent them
+	Bids     uint64 `json:"bids"`
+	Builders int    `json:"builders"`
+}
+
+// auctionLog records the valid bids of each builder per slot and the winning bid of
+// each slot
+type auctionLog struct {
+	lock    sync.Mutex
+	bids    map[uint64]map[string]uint64
+	winners []*WinningBid
+}
+
+func newAuctionLog() *auctionLog {
+	return &auctionLog{bids: map[uint64]map[string]uint64{}}
+}
+
+func (a *auctionLog) recordBid(slot uint64, builder string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if _, ok := a.bids[slot]; !ok {
+		a.bids[slot] = map[string]uint64{}
+	}
+	a.bids[slot][builder]++
+}
+
+func (a *auctionLog) recordWinner(bidTrace *common.BidTraceV2WithBlobFields) *WinningBid {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	winner := &WinningBid{
+		Slot:          bidTrace.Slot,
+		BlockHash:     bidTrace.BlockHash.String(),
+		BuilderPubkey: bidTrace.BuilderPubkey.String(),
+		Value:         bidTrace.Value.ToBig().String(),
+		Builders:      len(a.bids[bidTrace.Slot]),
+	}
+	for _, count := range a.bids[bidTrace.Slot] {
+		winner.Bids += count
+	}
+	a.winners = append(a.winners, winner)
+
+	// the bids of this slot and the previous ones are not needed anymore
+	for slot := range a.bids {
+		if slot <= bidTrace.Slot {
+			delete(a.bids, slot)
+		}
+	}
+	return winner
+}
+
+func (a *auctionLog) winningBids() []*WinningBid {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	winners := make([]*WinningBid, len(a.winners))
+	copy(winners, a.winners)
+	return winners
+}
+
+// WinningBids returns the winning bid of each slot with a delivered payload
+func (m *MevBoostRelay) WinningBids() []*WinningBid {
+	return m.auction.winningBids()
+}
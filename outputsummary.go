@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/spf13/cobra"
+)
+
+// outputSummaryArtifact has the details of the running playground for the scripts
+const outputSummaryArtifact = "output.json"
+
+// outputSummary is what the scripts need to connect to the playground
+type outputSummary struct {
+	SessionID string                       `json:"session_id"`
+	Endpoints map[string]map[string]string `json:"endpoints"`
+	Enodes    map[string]string            `json:"enodes"`
+
+	// JWTPath is the jwt secret of the engine api of reth
+	JWTPath string `json:"jwt_path"`
+
+	// GenesisHash is only set for the local devnet
+	GenesisHash string `json:"genesis_hash,omitempty"`
+}
+
+// writeOutputSummary writes the output.json file once the services are set up
+func writeOutputSummary(id string, out *output, keys *keyRegistry) error {
+	endpoints, err := loadEndpoints(out)
+	if err != nil {
+		return err
+	}
+	dst, err := filepath.Abs(out.dst)
+	if err != nil {
+		return err
+	}
+
+	summary := &outputSummary{
+		SessionID: id,
+		Endpoints: endpoints,
+		Enodes:    map[string]string{},
+		JWTPath:   filepath.Join(dst, engineJWTArtifact("reth")),
+	}
+	if summary.Enodes["reth"], err = keys.RethEnode(); err != nil {
+		return err
+	}
+	for _, f := range rethFollowers() {
+		if summary.Enodes[f.name], err = keys.RethFollowerEnode(f); err != nil {
+			return err
+		}
+	}
+
+	if networkFlag == "" {
+		data, err := out.ReadFile("genesis.json")
+		if err != nil {
+			return err
+		}
+		var genesis core.Genesis
+		if err := json.Unmarshal(data, &genesis); err != nil {
+			return err
+		}
+		summary.GenesisHash = genesis.ToBlock().Hash().String()
+	}
+	return out.WriteFile(outputSummaryArtifact, summary)
+}
+
+var outputCmd = &cobra.Command{
+	Use:   "output [session]",
+	Short: "Print the endpoints and details of the playground as json",
+	Long:  `Print the output.json file of the playground running in the output folder (or the session with the given id, see ls): the session id, the endpoints of each service, the enode addresses of reth, the path of the jwt secret and the genesis hash`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		} else if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		data, err := out.ReadFile(outputSummaryArtifact)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("%s not found in %s, is the playground running?", outputSummaryArtifact, out.dst)
+			}
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
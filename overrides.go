@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+var overrideFlag []string
+
+// serviceOverrides are the args and env of --override by service
+var serviceOverrides map[string]*serviceOverride
+
+// serviceOverride are the extra args and env variables of a service
+type serviceOverride struct {
+	args []string
+	env  []string
+}
+
+// parseOverrides parses the overrides of the form <service>.args+=<args> (the args are
+// split by spaces) or <service>.env.<name>=<value>
+func parseOverrides(items []string) (map[string]*serviceOverride, error) {
+	overrides := map[string]*serviceOverride{}
+	for _, item := range items {
+		name, spec, ok := strings.Cut(item, ".")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid override '%s', expected <service>.args+=<args> or <service>.env.<name>=<value>", item)
+		}
+		ov, ok := overrides[name]
+		if !ok {
+			ov = &serviceOverride{}
+			overrides[name] = ov
+		}
+
+		if args, ok := strings.CutPrefix(spec, "args+="); ok {
+			fields := strings.Fields(args)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("invalid override '%s', no args", item)
+			}
+			ov.args = append(ov.args, fields...)
+		} else if env, ok := strings.CutPrefix(spec, "env."); ok {
+			key, value, ok := strings.Cut(env, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid override '%s', expected %s.env.<name>=<value>", item, name)
+			}
+			ov.env = append(ov.env, key+"="+value)
+		} else {
+			return nil, fmt.Errorf("invalid override '%s', expected <service>.args+=<args> or <service>.env.<name>=<value>", item)
+		}
+	}
+	return overrides, nil
+}
+
+// apply appends the args and the env variables of the override to the service. The args
+// go after the ones of the service, so they take precedence for the clients that keep the
+// last value of a repeated flag.
+func (o *serviceOverride) apply(ss *service) {
+	ss.WithArgs(append([]string{}, o.args...)...)
+	for _, env := range o.env {
+		key, value, _ := strings.Cut(env, "=")
+		ss.WithEnv(key, value)
+	}
+}
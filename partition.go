@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// partitionChain is the iptables chain with the rules of the partition
+const partitionChain = "PLAYGROUND-PARTITION"
+
+// partitionArtifact records the groups of the current partition
+const partitionArtifact = "partition.json"
+
+var partitionDryRunFlag bool
+
+var partitionCmd = &cobra.Command{
+	Use:   "partition",
+	Short: "Split the services in groups that cannot reach each other",
+}
+
+var partitionCreateCmd = &cobra.Command{
+	Use:   "create <group>=<service>,<service> <group>=<service>,...",
+	Short: "Create a network partition between groups of services",
+	Long:  `Block the connections between the services of different groups with iptables rules in the loopback interface (requires root). The rules reject the connections to the ports of the services of a group from the services of the other groups, following the connections in topology.json. Use 'partition heal' to remove them.`,
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		groups, err := parsePartitionGroups(args)
+		if err != nil {
+			return err
+		}
+		topo, err := loadTopology(out)
+		if err != nil {
+			return err
+		}
+		rules, err := partitionRules(topo, groups)
+		if err != nil {
+			return err
+		}
+
+		// the chain and its jump from OUTPUT might exist from a previous partition
+		chainExists, jumpExists := false, false
+		if !partitionDryRunFlag {
+			if chainExists, err = partitionChainExists(); err != nil {
+				return err
+			}
+			jumpExists = chainExists && partitionJumpExists()
+		}
+
+		cmds := [][]string{}
+		if !chainExists {
+			cmds = append(cmds, []string{"-N", partitionChain})
+		}
+		cmds = append(cmds, []string{"-F", partitionChain})
+		if !jumpExists {
+			cmds = append(cmds, []string{"-I", "OUTPUT", "-j", partitionChain})
+		}
+		for _, rule := range rules {
+			cmds = append(cmds, append([]string{"-A", partitionChain}, rule.args()...))
+		}
+		if err := runIPTables(cmds); err != nil {
+			return err
+		}
+		if partitionDryRunFlag {
+			return nil
+		}
+
+		for _, rule := range rules {
+			fmt.Printf("Blocked %s\n", rule)
+		}
+		if err := out.WriteFile(partitionArtifact, groups); err != nil {
+			return err
+		}
+		return appendEvent(out, "partition created: "+strings.Join(args, " "))
+	},
+}
+
+var partitionHealCmd = &cobra.Command{
+	Use:   "heal",
+	Short: "Remove the network partition",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		chainExists, jumpExists := true, true
+		if !partitionDryRunFlag {
+			var err error
+			if chainExists, err = partitionChainExists(); err != nil {
+				return err
+			}
+			jumpExists = chainExists && partitionJumpExists()
+		}
+		if !chainExists {
+			if err := out.Remove(partitionArtifact); err != nil {
+				return err
+			}
+			fmt.Println("There is no partition to heal")
+			return nil
+		}
+
+		cmds := [][]string{}
+		if jumpExists {
+			cmds = append(cmds, []string{"-D", "OUTPUT", "-j", partitionChain})
+		}
+		cmds = append(cmds, []string{"-F", partitionChain}, []string{"-X", partitionChain})
+		if err := runIPTables(cmds); err != nil {
+			return err
+		}
+		if partitionDryRunFlag {
+			return nil
+		}
+
+		if err := out.Remove(partitionArtifact); err != nil {
+			return err
+		}
+		fmt.Println("Partition healed")
+		return appendEvent(out, "partition healed")
+	},
+}
+
+// parsePartitionGroups parses the groups of the form <group>=<service>,<service>
+func parsePartitionGroups(args []string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	seen := map[string]string{}
+	for _, arg := range args {
+		name, list, ok := strings.Cut(arg, "=")
+		if !ok || name == "" || list == "" {
+			return nil, fmt.Errorf("invalid group '%s', expected <group>=<service>,<service>", arg)
+		}
+		if _, ok := groups[name]; ok {
+			return nil, fmt.Errorf("group '%s' is defined twice", name)
+		}
+		for _, service := range strings.Split(list, ",") {
+			if other, ok := seen[service]; ok {
+				return nil, fmt.Errorf("service '%s' is in the groups '%s' and '%s'", service, other, name)
+			}
+			seen[service] = name
+			groups[name] = append(groups[name], service)
+		}
+	}
+	return groups, nil
+}
+
+// partitionRule blocks the connections to a port of a service
+type partitionRule struct {
+	service  string
+	port     *topologyPort
+	protocol string
+}
+
+func (r *partitionRule) args() []string {
+	args := []string{"-o", "lo", "-p", r.protocol, "--dport", strconv.Itoa(r.port.Port)}
+	if r.protocol == "tcp" {
+		return append(args, "-j", "REJECT", "--reject-with", "tcp-reset")
+	}
+	return append(args, "-j", "DROP")
+}
+
+func (r *partitionRule) String() string {
+	return fmt.Sprintf("%s:%s (%s/%d)", r.service, r.port.Name, r.protocol, r.port.Port)
+}
+
+// partitionRules returns the rules that block the connections between the groups. A port
+// is blocked if a service of another group connects to it, so the services of its own
+// group (or out of the groups) that connect to it are cut too, which is reported as an error.
+func partitionRules(topo *topology, groups map[string][]string) ([]*partitionRule, error) {
+	groupOf := map[string]string{}
+	for group, services := range groups {
+		for _, service := range services {
+			groupOf[service] = group
+		}
+	}
+
+	nodes := map[string]*topologyNode{}
+	for _, node := range topo.Nodes {
+		nodes[node.Name] = node
+	}
+	for service := range groupOf {
+		if _, ok := nodes[service]; !ok {
+			return nil, fmt.Errorf("service '%s' not found in the topology", service)
+		}
+	}
+
+	findPort := func(service, name string) *topologyPort {
+		for _, p := range nodes[service].Ports {
+			if p.Name == name {
+				return p
+			}
+		}
+		return nil
+	}
+
+	// the ports with a connection from another group
+	blocked := map[string]*topologyPort{}
+	for _, edge := range topo.Edges {
+		from, okFrom := groupOf[edge.From]
+		to, okTo := groupOf[edge.To]
+		if !okFrom || !okTo || from == to {
+			continue
+		}
+		if p := findPort(edge.To, edge.Port); p != nil {
+			blocked[edge.To+":"+edge.Port] = p
+		}
+		// the p2p connections are dialed from both sides
+		if edge.Type == dependencyP2P {
+			if p := findPort(edge.From, edge.Port); p != nil {
+				blocked[edge.From+":"+edge.Port] = p
+			}
+		}
+	}
+
+	// a blocked port cannot have clients on its own side
+	for _, edge := range topo.Edges {
+		if _, ok := blocked[edge.To+":"+edge.Port]; !ok || edge.Type == dependencyP2P {
+			continue
+		}
+		if group, ok := groupOf[edge.From]; !ok || group == groupOf[edge.To] {
+			return nil, fmt.Errorf("cannot partition %s:%s, %s connects to it from the same side", edge.To, edge.Port, edge.From)
+		}
+	}
+
+	keys := []string{}
+	for key := range blocked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rules := []*partitionRule{}
+	for _, key := range keys {
+		service, _, _ := strings.Cut(key, ":")
+		p := blocked[key]
+		rules = append(rules, &partitionRule{service: service, port: p, protocol: "tcp"})
+		if p.Protocol == protocolP2P {
+			rules = append(rules, &partitionRule{service: service, port: p, protocol: "udp"})
+		}
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("the groups do not connect to each other, there is nothing to partition")
+	}
+	return rules, nil
+}
+
+// runIPTables runs the iptables commands and stops at the first failure
+func runIPTables(cmds [][]string) error {
+	for _, args := range cmds {
+		if partitionDryRunFlag {
+			fmt.Println("iptables " + strings.Join(args, " "))
+			continue
+		}
+		if output, err := exec.Command("iptables", args...).CombinedOutput(); err != nil {
+			return iptablesError(args, err, output)
+		}
+	}
+	return nil
+}
+
+func iptablesError(args []string, err error, output []byte) error {
+	return fmt.Errorf("iptables %s failed (it requires root): %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+}
+
+// partitionChainExists returns whether the chain of the partition exists. Any failure
+// other than a missing chain (i.e. not running as root) is returned.
+func partitionChainExists() (bool, error) {
+	args := []string{"-n", "-L", partitionChain}
+	output, err := exec.Command("iptables", args...).CombinedOutput()
+	if err == nil {
+		return true, nil
+	}
+	if strings.Contains(string(output), "No chain/target/match") {
+		return false, nil
+	}
+	return false, iptablesError(args, err, output)
+}
+
+// partitionJumpExists returns whether OUTPUT jumps to the chain of the partition
+func partitionJumpExists() bool {
+	return exec.Command("iptables", "-C", "OUTPUT", "-j", partitionChain).Run() == nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause <service>",
+	Short: "Pause a running service",
+	Long:  `Freeze the process of a running service (SIGSTOP) to trigger the missed slots and timeouts of the services that depend on it`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return signalService(args[0], syscall.SIGSTOP, "paused")
+	},
+}
+
+var resumeAllFlag bool
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <service>",
+	Short: "Resume a paused service",
+	Long:  `Resume a paused service, or all the services with --all (i.e. after --freeze-at)`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if resumeAllFlag {
+			if len(args) != 0 {
+				return fmt.Errorf("--all does not take a service")
+			}
+			if err := resolveOutputFlag(); err != nil {
+				return err
+			}
+			return signalAllServices(&output{dst: outputFlag}, syscall.SIGCONT, "resumed")
+		}
+		if len(args) != 1 {
+			return fmt.Errorf("missing the service to resume")
+		}
+		return signalService(args[0], syscall.SIGCONT, "resumed")
+	},
+}
+
+func pidFilePath(name string) string {
+	return filepath.Join("pids", name+".pid")
+}
+
+// signalService sends the signal to the process group of a service started by
+// the playground running on the output folder and records the event.
+func signalService(name string, sig syscall.Signal, event string) error {
+	if err := resolveOutputFlag(); err != nil {
+		return err
+	}
+	return signalServiceIn(&output{dst: outputFlag}, name, sig, event)
+}
+
+func signalServiceIn(out *output, name string, sig syscall.Signal, event string) error {
+	data, err := os.ReadFile(filepath.Join(out.dst, pidFilePath(name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("service '%s' is not running in %s", name, out.dst)
+		}
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("invalid pid file for service '%s': %w", name, err)
+	}
+
+	if err := syscall.Kill(-pid, sig); err != nil {
+		return fmt.Errorf("failed to signal service '%s': %w", name, err)
+	}
+	fmt.Printf("Service %s %s\n", name, event)
+
+	return appendEvent(out, fmt.Sprintf("service %s %s", name, event))
+}
+
+// signalAllServices sends the signal to all the running services of the output folder.
+// It returns the error of the first service that could not be signaled.
+func signalAllServices(out *output, sig syscall.Signal, event string) error {
+	pidFiles, err := filepath.Glob(filepath.Join(out.dst, pidFilePath("*")))
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, pidFile := range pidFiles {
+		if _, _, running := readPidFile(pidFile); !running {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(pidFile), ".pid")
+		if err := signalServiceIn(out, name, sig, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// appendEvent records an event in the events.log file of the output folder
+func appendEvent(out *output, event string) error {
+	f, err := os.OpenFile(filepath.Join(out.dst, "events.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s %s\n", time.Now().Format(time.RFC3339), event)
+	return err
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// preStopTimeout is the maximum time a pre-stop hook can run
+const preStopTimeout = 10 * time.Second
+
+// preStopHook is an action that runs before the service is stopped. It is either
+// a command or a function.
+type preStopHook struct {
+	args []string
+	fn   func(ctx context.Context) error
+}
+
+func (p *preStopHook) String() string {
+	if p.args != nil {
+		return strings.Join(p.args, " ")
+	}
+	return "function"
+}
+
+func (p *preStopHook) Run(ctx context.Context, logOutput io.Writer) error {
+	if p.fn != nil {
+		return p.fn(ctx)
+	}
+	cmd := exec.CommandContext(ctx, p.args[0], p.args[1:]...)
+	cmd.Stdout = logOutput
+	cmd.Stderr = logOutput
+	return cmd.Run()
+}
+
+// WithPreStop runs the function before the service is stopped (i.e. to flush its state)
+func (s *service) WithPreStop(fn func(ctx context.Context) error) *service {
+	s.preStop = append(s.preStop, &preStopHook{fn: fn})
+	return s
+}
+
+// WithPreStopCmd runs the command before the service is stopped
+func (s *service) WithPreStopCmd(args ...string) *service {
+	tmplVars := s.tmplVars()
+	for i, arg := range args {
+		args[i] = applyTemplate(arg, tmplVars)
+	}
+	s.preStop = append(s.preStop, &preStopHook{args: args})
+	return s
+}
+
+// runPreStopHooks runs the pre-stop hooks of the running services. The services are
+// processed in reverse dependency order: a service runs its hooks before the
+// services it depends on.
+func (s *serviceManager) runPreStopHooks() {
+	for _, h := range reverseDependencyOrder(s.handles) {
+		for _, hook := range h.Service.preStop {
+			select {
+			case <-h.doneCh:
+				// the service is not running anymore
+				continue
+			default:
+			}
+
+			logger.Info("Running pre-stop hook", "service", h.Service.name, "hook", hook)
+			fmt.Fprintf(h.logOutput, "\nRunning pre-stop hook: %s\n", hook)
+
+			// the context of the service manager is already cancelled at this point
+			ctx, cancel := context.WithTimeout(context.Background(), preStopTimeout)
+			err := hook.Run(ctx, h.logOutput)
+			cancel()
+
+			if err != nil {
+				logger.Warn("Pre-stop hook failed", "service", h.Service.name, "err", err)
+				fmt.Fprintf(h.logOutput, "Pre-stop hook failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// reverseDependencyOrder sorts the handles so that each service comes before the
+// services it depends on. Services without dependencies between them keep the
+// reverse start order.
+func reverseDependencyOrder(handles []*handle) []*handle {
+	// number of services that depend on each service
+	dependents := map[string]int{}
+	for _, h := range handles {
+		for _, dep := range h.Service.deps {
+			dependents[dep.service]++
+		}
+	}
+
+	pending := make([]*handle, len(handles))
+	for i, h := range handles {
+		pending[len(handles)-1-i] = h
+	}
+
+	result := []*handle{}
+	for len(pending) != 0 {
+		idx := -1
+		for i, h := range pending {
+			if dependents[h.Service.name] == 0 {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			// dependency cycle, keep the remaining ones in reverse start order
+			return append(result, pending...)
+		}
+
+		h := pending[idx]
+		pending = append(pending[:idx], pending[idx+1:]...)
+		result = append(result, h)
+		for _, dep := range h.Service.deps {
+			dependents[dep.service]--
+		}
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// udpProbe checks that there is a process listening on an udp port. Since udp has no
+// handshake, it only fails if the host reports the port as unreachable.
+func udpProbe(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return err
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			// no response and no 'port unreachable' error
+			return nil
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return fmt.Errorf("udp port %s is not reachable", addr)
+		}
+		return err
+	}
+	return nil
+}
+
+// discv5Ping sends a discv5 PING to the node with the given ENR at the address addr,
+// which can be different from the address in the ENR (i.e. the public ip of the node).
+func discv5Ping(enr string, addr *net.UDPAddr) error {
+	node, err := enode.Parse(enode.ValidSchemes, enr)
+	if err != nil {
+		return fmt.Errorf("invalid enr: %w", err)
+	}
+	if node.Pubkey() == nil {
+		return fmt.Errorf("enr without public key")
+	}
+	node = enode.NewV4(node.Pubkey(), addr.IP, addr.Port, addr.Port)
+
+	// use a new ephemeral identity for the probe
+	key, err := ecrypto.GenerateKey()
+	if err != nil {
+		return err
+	}
+	db, err := enode.OpenDB("")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return err
+	}
+	transport, err := discover.ListenV5(conn, enode.NewLocalNode(db, key), discover.Config{
+		PrivateKey: key,
+		Log:        log.NewLogger(log.DiscardHandler()),
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer transport.Close()
+
+	return transport.Ping(node)
+}
+
+// beaconNodeP2PProbe pings the discovery port of the beacon node with the ENR it reports
+// in the beacon api
+func beaconNodeP2PProbe(ctx context.Context) error {
+	var identity struct {
+		Data struct {
+			ENR string `json:"enr"`
+		} `json:"data"`
+	}
+	if err := httpGetJSON(ctx, "http://localhost:3500/eth/v1/node/identity", &identity); err != nil {
+		return err
+	}
+	return discv5Ping(identity.Data.ENR, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000})
+}
+
+// rethP2PProbe checks the discovery port of reth
+func rethP2PProbe() error {
+	return udpProbe("127.0.0.1:30303", time.Second)
+}
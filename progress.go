@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// formats of the progress of the playground
+const (
+	progressText = "text"
+	progressJSON = "json"
+)
+
+// types of the progress events
+const (
+	progressDownloaded     = "downloaded"
+	progressServiceWaiting = "service_waiting"
+	progressServiceStarted = "service_started"
+	progressServiceExited  = "service_exited"
+	progressJobCompleted   = "job_completed"
+	progressCronFailing    = "cron_failing"
+	progressCronRecovered  = "cron_recovered"
+	progressReadiness      = "readiness"
+	progressReady          = "ready"
+	progressStopping       = "stopping"
+	progressStopped        = "stopped"
+)
+
+var progressFormatFlag string
+
+// progressEvent is a step of the playground. With --progress-format json the events are
+// written to stdout, one per line.
+type progressEvent struct {
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Service  string    `json:"service,omitempty"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+type progressEmitter struct {
+	lock sync.Mutex
+	out  io.Writer
+}
+
+// progress is nil unless the progress is emitted as json
+var progress *progressEmitter
+
+// setupProgress validates --progress-format. In json mode stdout is reserved for the
+// events and the rest of the output is moved to stderr.
+func setupProgress() error {
+	switch progressFormatFlag {
+	case progressText:
+		return nil
+	case progressJSON:
+	default:
+		return fmt.Errorf("unknown --progress-format '%s', it must be %s or %s", progressFormatFlag, progressText, progressJSON)
+	}
+	if progress == nil {
+		progress = &progressEmitter{out: os.Stdout}
+		os.Stdout = os.Stderr
+	}
+	return nil
+}
+
+func emitProgress(event *progressEvent) {
+	if progress == nil {
+		return
+	}
+	event.Time = time.Now()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	progress.lock.Lock()
+	defer progress.lock.Unlock()
+
+	progress.out.Write(append(data, '\n'))
+}
+
+// newReadyWatcher emits the ready event once the chain produces the first block
+func newReadyWatcher() func(ctx context.Context) error {
+	ready := false
+
+	return func(ctx context.Context) error {
+		if ready {
+			return nil
+		}
+		// reth is not reachable until it starts, it is not a failure of the job
+		var blockNumber string
+		if err := rpcCall(ctx, "http://localhost:8545", "eth_blockNumber", nil, &blockNumber); err != nil {
+			return nil
+		}
+		num, err := strconv.ParseUint(blockNumber, 0, 64)
+		if err != nil {
+			return err
+		}
+		if num > 0 {
+			emitProgress(&progressEvent{Type: progressReady, Message: "first block produced"})
+			ready = true
+		}
+		return nil
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	mevboostrelay "github.com/ferranbt/builder-playground/mev-boost-relay"
+)
+
+// readinessArtifact is written in the output folder when a service reaches a readiness
+// stage (i.e. the relay received the first bid). The services that need the stage wait
+// for it with DependsOnArtifact.
+func readinessArtifact(service, stage string) string {
+	return filepath.Join("readiness", service, stage)
+}
+
+// newRelayReadinessWatcher reports the readiness stages reached by the relay
+func newRelayReadinessWatcher(out *output, relay *mevboostrelay.MevBoostRelay) func(ctx context.Context) error {
+	reported := map[string]bool{}
+
+	return func(ctx context.Context) error {
+		stages := relay.Stages()
+
+		names := []string{}
+		for stage := range stages {
+			if !reported[stage] {
+				names = append(names, stage)
+			}
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return stages[names[i]].Before(stages[names[j]])
+		})
+
+		for _, stage := range names {
+			if err := out.WriteFile(readinessArtifact("mev-boost-relay", stage), stages[stage].Format(time.RFC3339)); err != nil {
+				return err
+			}
+			if err := appendEvent(out, "mev-boost-relay reached "+stage); err != nil {
+				return err
+			}
+			logger.Info("Relay ready", "stage", stage)
+			emitProgress(&progressEvent{Type: progressReadiness, Service: "mev-boost-relay", Message: stage})
+			reported[stage] = true
+		}
+		return nil
+	}
+}
+
+// p2pReadinessStage is reached when the p2p port of the service is reachable
+const p2pReadinessStage = "p2p"
+
+// newP2PReadinessWatcher reports when the p2p ports of reth and the beacon node are
+// reachable, so the services that peer with them (i.e. the reth followers) start once
+// they can connect instead of waiting for the next dial.
+func newP2PReadinessWatcher(out *output) func(ctx context.Context) error {
+	probes := map[string]func(ctx context.Context) error{
+		"reth": func(ctx context.Context) error {
+			return rethP2PProbe()
+		},
+		"beacon_node": beaconNodeP2PProbe,
+	}
+	reported := map[string]bool{}
+
+	return func(ctx context.Context) error {
+		for _, service := range []string{"reth", "beacon_node"} {
+			if reported[service] {
+				continue
+			}
+			// the service is not reachable until it starts, it is not a failure of the job
+			if err := probes[service](ctx); err != nil {
+				continue
+			}
+			if err := out.WriteFile(readinessArtifact(service, p2pReadinessStage), time.Now().Format(time.RFC3339)); err != nil {
+				return err
+			}
+			if err := appendEvent(out, service+" reached "+p2pReadinessStage); err != nil {
+				return err
+			}
+			logger.Info("P2P port reachable", "service", service)
+			emitProgress(&progressEvent{Type: progressReadiness, Service: service, Message: p2pReadinessStage})
+			reported[service] = true
+		}
+		return nil
+	}
+}
@@ -0,0 +1,354 @@
+package relayloadgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	builderApiDeneb "github.com/attestantio/go-builder-client/api/deneb"
+	builderApiV1 "github.com/attestantio/go-builder-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/flashbots/go-boost-utils/bls"
+	"github.com/flashbots/go-boost-utils/ssz"
+	"github.com/flashbots/go-boost-utils/utils"
+	"github.com/flashbots/mev-boost-relay/beaconclient"
+	"github.com/flashbots/mev-boost-relay/common"
+	"github.com/holiman/uint256"
+	"github.com/sirupsen/logrus"
+)
+
+// extraData marks the blocks of the load generator in the relay logs and data api
+var extraData = []byte("playground-loadgen")
+
+type Config struct {
+	LogOutput io.Writer
+	RelayURL  string
+	BeaconURL string
+
+	// Rate is the number of submissions per second
+	Rate float64
+
+	// Value is the distribution of the values of the submissions
+	Value *ValueDistribution
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		LogOutput: os.Stdout,
+		RelayURL:  "http://localhost:5555",
+		BeaconURL: "http://localhost:3500",
+		Rate:      10,
+		Value:     &ValueDistribution{kind: valueUniform, min: 1, max: 100},
+	}
+}
+
+// Stats are the results of the submissions to the relay
+type Stats struct {
+	Submitted uint64 `json:"submitted"`
+	Accepted  uint64 `json:"accepted"`
+
+	// Rejected is the number of rejected submissions by the error of the relay
+	Rejected map[string]uint64 `json:"rejected"`
+}
+
+// LoadGen submits synthetic blocks to the builder api of the relay. The blocks have a valid
+// structure for the slot (parent, prev randao, withdrawals, proposer and timestamp) and are
+// signed with a random builder key, but their execution payload is not a real block.
+type LoadGen struct {
+	config *Config
+	log    *logrus.Entry
+	beacon *beaconclient.ProdBeaconInstance
+	client *http.Client
+
+	sk     *bls.SecretKey
+	pubkey phase0.BLSPubKey
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	statsLock sync.Mutex
+	stats     Stats
+}
+
+func New(config *Config) (*LoadGen, error) {
+	if config.Rate <= 0 {
+		return nil, fmt.Errorf("the rate must be positive")
+	}
+
+	log := common.LogSetup(false, "info")
+	log.Logger.SetOutput(config.LogOutput)
+
+	sk, pk, err := bls.GenerateNewKeypair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate the builder key: %w", err)
+	}
+	var pubkey phase0.BLSPubKey
+	copy(pubkey[:], bls.PublicKeyToBytes(pk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LoadGen{
+		config: config,
+		log:    log,
+		beacon: beaconclient.NewProdBeaconInstance(log, config.BeaconURL, config.BeaconURL),
+		client: &http.Client{Timeout: 10 * time.Second},
+		sk:     sk,
+		pubkey: pubkey,
+		ctx:    ctx,
+		cancel: cancel,
+		stats:  Stats{Rejected: map[string]uint64{}},
+	}, nil
+}
+
+// Run submits blocks for every slot announced by the payload attributes of the beacon node
+// until Close is called
+func (l *LoadGen) Run() error {
+	domain, err := l.builderDomain()
+	if err != nil {
+		return err
+	}
+	l.log.Infof("Submitting %.1f blocks per second with builder %s", l.config.Rate, l.pubkey.String())
+
+	attrsCh := make(chan beaconclient.PayloadAttributesEvent, 16)
+	go l.beacon.SubscribeToPayloadAttributesEvents(attrsCh)
+
+	var lastKey string
+	cancelSlot := func() {}
+	for {
+		select {
+		case <-l.ctx.Done():
+			cancelSlot()
+			return nil
+		case event := <-attrsCh:
+			// the beacon node sends the attributes again while it prepares the payload
+			key := fmt.Sprintf("%s-%d", event.Data.ParentBlockHash, event.Data.ProposalSlot)
+			if key == lastKey {
+				continue
+			}
+			lastKey = key
+			if event.Version != "deneb" {
+				l.log.Warnf("Skipping slot %d, fork %s not supported", event.Data.ProposalSlot, event.Version)
+				continue
+			}
+
+			// the submissions for a slot stop at its timestamp or with the next slot
+			cancelSlot()
+			deadline := time.Unix(int64(event.Data.PayloadAttributes.Timestamp), 0)
+			var ctx context.Context
+			ctx, cancelSlot = context.WithDeadline(l.ctx, deadline)
+			go l.submitSlot(ctx, domain, event.Data)
+		}
+	}
+}
+
+// Close stops the submissions
+func (l *LoadGen) Close() error {
+	l.cancel()
+	return nil
+}
+
+// Stats returns the results of the submissions so far
+func (l *LoadGen) Stats() *Stats {
+	l.statsLock.Lock()
+	defer l.statsLock.Unlock()
+
+	stats := &Stats{Submitted: l.stats.Submitted, Accepted: l.stats.Accepted, Rejected: map[string]uint64{}}
+	for msg, count := range l.stats.Rejected {
+		stats.Rejected[msg] = count
+	}
+	return stats
+}
+
+// builderDomain returns the domain of the builder signatures. It waits for the beacon node.
+func (l *LoadGen) builderDomain() (phase0.Domain, error) {
+	for {
+		genesis, err := l.beacon.GetGenesis()
+		if err == nil {
+			return common.ComputeDomain(ssz.DomainTypeAppBuilder, genesis.Data.GenesisForkVersion, phase0.Root{}.String())
+		}
+		select {
+		case <-l.ctx.Done():
+			return phase0.Domain{}, l.ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (l *LoadGen) submitSlot(ctx context.Context, domain phase0.Domain, attrs beaconclient.PayloadAttributesEventData) {
+	duty, err := l.proposerDuty(ctx, attrs.ProposalSlot)
+	if err != nil {
+		l.log.WithError(err).Warnf("Skipping slot %d", attrs.ProposalSlot)
+		return
+	}
+
+	var wg sync.WaitGroup
+	var submitted, accepted atomic.Uint64
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / l.config.Rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			l.log.Infof("Slot %d: %d submissions, %d accepted", attrs.ProposalSlot, submitted.Load(), accepted.Load())
+			return
+		case <-ticker.C:
+		}
+
+		req, err := l.newSubmission(domain, &attrs, duty)
+		if err != nil {
+			l.log.WithError(err).Error("Failed to create the submission")
+			continue
+		}
+		// the submissions do not wait for the previous ones to keep the rate
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			submitted.Add(1)
+			if l.submit(req) {
+				accepted.Add(1)
+			}
+		}()
+	}
+}
+
+// proposerDuty returns the registration of the proposer of the slot from the relay
+func (l *LoadGen) proposerDuty(ctx context.Context, slot uint64) (*builderApiV1.ValidatorRegistration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.config.RelayURL+"/relay/v1/builder/validators", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var duties []*common.BuilderGetValidatorsResponseEntry
+	if err := json.NewDecoder(resp.Body).Decode(&duties); err != nil {
+		return nil, fmt.Errorf("failed to decode the proposer duties: %w", err)
+	}
+	for _, duty := range duties {
+		if duty.Slot == slot && duty.Entry != nil && duty.Entry.Message != nil {
+			return duty.Entry.Message, nil
+		}
+	}
+	return nil, fmt.Errorf("no registered proposer for the slot")
+}
+
+func (l *LoadGen) newSubmission(domain phase0.Domain, attrs *beaconclient.PayloadAttributesEventData, duty *builderApiV1.ValidatorRegistration) (*builderApiDeneb.SubmitBlockRequest, error) {
+	parentHash, err := utils.HexToHash(attrs.ParentBlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parent hash: %w", err)
+	}
+	prevRandao, err := utils.HexToHash(attrs.PayloadAttributes.PrevRandao)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prev randao: %w", err)
+	}
+	withdrawals := attrs.PayloadAttributes.Withdrawals
+	if withdrawals == nil {
+		withdrawals = []*capella.Withdrawal{}
+	}
+
+	tx := make([]byte, 128)
+	var blockHash phase0.Hash32
+	var stateRoot, receiptsRoot phase0.Root
+	for _, b := range [][]byte{tx, blockHash[:], stateRoot[:], receiptsRoot[:]} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	payload := &deneb.ExecutionPayload{
+		ParentHash:    parentHash,
+		FeeRecipient:  duty.FeeRecipient,
+		StateRoot:     stateRoot,
+		ReceiptsRoot:  receiptsRoot,
+		PrevRandao:    prevRandao,
+		BlockNumber:   attrs.ParentBlockNumber + 1,
+		GasLimit:      duty.GasLimit,
+		GasUsed:       21000,
+		Timestamp:     attrs.PayloadAttributes.Timestamp,
+		ExtraData:     extraData,
+		BaseFeePerGas: uint256.NewInt(7),
+		BlockHash:     blockHash,
+		Transactions:  []bellatrix.Transaction{tx},
+		Withdrawals:   withdrawals,
+	}
+	bidTrace := &builderApiV1.BidTrace{
+		Slot:                 attrs.ProposalSlot,
+		ParentHash:           parentHash,
+		BlockHash:            blockHash,
+		BuilderPubkey:        l.pubkey,
+		ProposerPubkey:       duty.Pubkey,
+		ProposerFeeRecipient: duty.FeeRecipient,
+		GasLimit:             payload.GasLimit,
+		GasUsed:              payload.GasUsed,
+		Value:                l.config.Value.sample(),
+	}
+	signature, err := ssz.SignMessage(bidTrace, domain, l.sk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign the bid: %w", err)
+	}
+
+	return &builderApiDeneb.SubmitBlockRequest{
+		Message:          bidTrace,
+		ExecutionPayload: payload,
+		BlobsBundle: &builderApiDeneb.BlobsBundle{
+			Commitments: []deneb.KZGCommitment{},
+			Proofs:      []deneb.KZGProof{},
+			Blobs:       []deneb.Blob{},
+		},
+		Signature: signature,
+	}, nil
+}
+
+// submit sends the block to the relay and records the result. It returns whether the
+// relay accepted it.
+func (l *LoadGen) submit(req *builderApiDeneb.SubmitBlockRequest) bool {
+	data, err := json.Marshal(req)
+	if err != nil {
+		l.log.WithError(err).Error("Failed to encode the submission")
+		return false
+	}
+
+	msg := ""
+	resp, err := l.client.Post(l.config.RelayURL+"/relay/v1/builder/blocks", "application/json", bytes.NewReader(data))
+	if err != nil {
+		msg = err.Error()
+	} else {
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			var relayErr struct {
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&relayErr); err != nil || relayErr.Message == "" {
+				relayErr.Message = resp.Status
+			}
+			msg = relayErr.Message
+		}
+	}
+
+	l.statsLock.Lock()
+	defer l.statsLock.Unlock()
+
+	l.stats.Submitted++
+	if msg != "" {
+		l.stats.Rejected[msg]++
+		return false
+	}
+	l.stats.Accepted++
+	return true
+}
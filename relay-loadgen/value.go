@@ -0,0 +1,71 @@
+package relayloadgen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/holiman/uint256"
+)
+
+// kinds of value distributions
+const (
+	valueUniform     = "uniform"
+	valueExponential = "exp"
+)
+
+// gwei is the unit of the values of the distribution
+const gwei = 1_000_000_000
+
+// ValueDistribution is the distribution of the values (in gwei) of the submissions
+type ValueDistribution struct {
+	kind string
+	min  float64
+	max  float64
+	mean float64
+}
+
+// ParseValueDistribution parses a distribution of values in gwei, either <min>-<max> for
+// uniformly distributed values or exp:<mean> for exponentially distributed values
+func ParseValueDistribution(s string) (*ValueDistribution, error) {
+	if mean, ok := strings.CutPrefix(s, valueExponential+":"); ok {
+		v, err := strconv.ParseFloat(mean, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid mean '%s', it must be a positive number of gwei", mean)
+		}
+		return &ValueDistribution{kind: valueExponential, mean: v}, nil
+	}
+
+	minStr, maxStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid value distribution '%s', expected <min>-<max> or exp:<mean> (in gwei)", s)
+	}
+	min, err := strconv.ParseFloat(minStr, 64)
+	if err != nil || min < 0 {
+		return nil, fmt.Errorf("invalid min '%s', it must be a number of gwei", minStr)
+	}
+	max, err := strconv.ParseFloat(maxStr, 64)
+	if err != nil || max < min {
+		return nil, fmt.Errorf("invalid max '%s', it must be a number of gwei not lower than the min", maxStr)
+	}
+	return &ValueDistribution{kind: valueUniform, min: min, max: max}, nil
+}
+
+// sample returns a value in wei. The relay ignores the blocks without value, so it is at least 1 wei.
+func (v *ValueDistribution) sample() *uint256.Int {
+	var value float64
+	switch v.kind {
+	case valueExponential:
+		value = rand.ExpFloat64() * v.mean
+	default:
+		value = v.min + rand.Float64()*(v.max-v.min)
+	}
+
+	wei := uint64(math.Min(value*gwei, 1<<63))
+	if wei == 0 {
+		wei = 1
+	}
+	return uint256.NewInt(wei)
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ferranbt/builder-playground/artifacts"
+	clproxy "github.com/ferranbt/builder-playground/cl-proxy"
+	"github.com/spf13/cobra"
+)
+
+// engineRecordingArtifact is the file of the output folder with the engine api requests
+// of the beacon node and the responses of reth, recorded by the cl-proxy
+const engineRecordingArtifact = "engine_recording.jsonl"
+
+// bundleInfoFile describes the session of a replay bundle
+const bundleInfoFile = "bundle.json"
+
+// bundleDir is the folder of the files in the tar.gz of a replay bundle
+const bundleDir = "playground-bundle"
+
+var recordEngineFlag bool
+var bundleFileFlag string
+var replaySpeedFlag float64
+var replayVerboseFlag bool
+
+// bundleFiles are the files of the output folder in a replay bundle and whether the
+// bundle requires them
+var bundleFiles = []struct {
+	path     string
+	required bool
+}{
+	{"genesis.json", true},
+	{engineRecordingArtifact, true},
+	{rpcRecordingArtifact, false},
+}
+
+type bundleInfo struct {
+	SessionID string    `json:"session_id,omitempty"`
+	Created   time.Time `json:"created"`
+
+	// RethVersion is the version of reth that produced the recording
+	RethVersion string `json:"reth_version,omitempty"`
+}
+
+var bundleCmd = &cobra.Command{
+	Use:   "bundle [session]",
+	Short: "Create a replay bundle with the recorded inputs of the playground",
+	Long:  `Gather the genesis, the engine api requests (recorded with --record-engine) and the JSON-RPC requests (recorded with --record-rpc) of the playground running in the output folder (or the session with the given id, see ls) into a tar.gz that the replay command reproduces on a new reth node`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		} else if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		if !out.Exists(engineRecordingArtifact) {
+			return fmt.Errorf("%s not found in %s, start the playground with --record-engine", engineRecordingArtifact, out.dst)
+		}
+		if bundleFileFlag == "" {
+			bundleFileFlag = fmt.Sprintf("playground-bundle-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		if err := writeBundle(out, bundleFileFlag); err != nil {
+			return err
+		}
+		fmt.Printf("Bundle written to %s\n", bundleFileFlag)
+		return nil
+	},
+}
+
+func writeBundle(out *output, path string) error {
+	info := &bundleInfo{Created: time.Now()}
+	var session sessionInfo
+	if data, err := out.ReadFile(sessionArtifact); err == nil && json.Unmarshal(data, &session) == nil {
+		info.SessionID = session.ID
+	}
+	if version, err := loadServiceVersion(out, "reth"); err == nil {
+		info.RethVersion = version.Version
+	}
+	infoRaw, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	hdr := &tar.Header{
+		Name:    filepath.Join(bundleDir, bundleInfoFile),
+		Mode:    0644,
+		Size:    int64(len(infoRaw)),
+		ModTime: info.Created,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(infoRaw); err != nil {
+		return err
+	}
+
+	for _, file := range bundleFiles {
+		if err := addBundleFile(tw, out, file.path); err != nil {
+			if os.IsNotExist(err) && !file.required {
+				continue
+			}
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func addBundleFile(tw *tar.Writer, out *output, path string) error {
+	src, err := os.Open(filepath.Join(out.dst, path))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    filepath.Join(bundleDir, path),
+		Mode:    0644,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, src, stat.Size())
+	return err
+}
+
+// extractBundle writes the files of the bundle to the output folder
+func extractBundle(path string, out *output) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid bundle: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rel, ok := strings.CutPrefix(filepath.Clean(hdr.Name), bundleDir+string(filepath.Separator))
+		if !ok || !filepath.IsLocal(rel) {
+			return fmt.Errorf("invalid bundle: unexpected file %s", hdr.Name)
+		}
+
+		dst := filepath.Join(out.dst, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		file, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(file, tr); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <bundle>",
+	Short: "Replay the recorded inputs of a bundle on a new reth node",
+	Long:  `Extract a bundle created with the bundle command to the output folder, start reth with its genesis and send it the recorded engine api and JSON-RPC requests in order, with the original timing. The blocks are imported from the recorded payloads, so the chain is the same as the one of the recorded session. It compares the responses with the recorded ones and the head block with the last one of the recording.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if replaySpeedFlag < 0 {
+			return fmt.Errorf("--speed must not be negative")
+		}
+		if outputFlag == "" {
+			homeDir, err := getHomeDir()
+			if err != nil {
+				return err
+			}
+			outputFlag = filepath.Join(homeDir, "replay")
+		}
+		out := &output{dst: outputFlag}
+		if err := checkReplayOutput(out); err != nil {
+			return err
+		}
+		if err := out.Remove(""); err != nil {
+			return err
+		}
+		if err := extractBundle(args[0], out); err != nil {
+			return err
+		}
+
+		var info bundleInfo
+		if data, err := out.ReadFile(bundleInfoFile); err == nil {
+			if err := json.Unmarshal(data, &info); err != nil {
+				return fmt.Errorf("invalid %s: %w", bundleInfoFile, err)
+			}
+		}
+		logger.Info("Replaying bundle", "session", info.SessionID, "created", info.Created, "reth", info.RethVersion)
+
+		engineRecords, err := readRPCRecording(filepath.Join(out.dst, engineRecordingArtifact))
+		if err != nil {
+			return fmt.Errorf("failed to read the engine recording: %w", err)
+		}
+		rpcRecords, err := readRPCRecording(filepath.Join(out.dst, rpcRecordingArtifact))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read the rpc recording: %w", err)
+		}
+
+		bins, err := artifacts.DownloadArtifacts([]string{"reth"}, releaseVersions(), downloadConfig())
+		if err != nil {
+			return err
+		}
+		keys := defaultKeyRegistry()
+		if err := out.WriteFile(jwtSecretArtifact, keys.JWTSecret()); err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		svcManager := newServiceManager(ctx, out)
+		defer svcManager.StopAndWait()
+
+		svcManager.NewService("reth").
+			WithArgs(
+				bins["reth"],
+				"node",
+				"--chain", "{{.Dir}}/genesis.json",
+				"--datadir", "{{.Dir}}/data_reth",
+				"--color", "never",
+				"--addr", "127.0.0.1",
+				"--port", "30303",
+				"--disable-discovery",
+				"--http",
+				"--http.api", "admin,eth,net,web3",
+				"--http.port", "8545",
+				"--authrpc.port", "8551",
+				"--authrpc.jwtsecret", "{{.Dir}}/"+jwtSecretArtifact,
+			).
+			WithPort("http", 8545, protocolHTTP).
+			WithPort("authrpc", 8551, protocolEngineAPI).
+			Run()
+
+		// stop the replay if reth exits
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-svcManager.NotifyErrCh():
+				logger.Error("reth stopped, see the log in the output folder")
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		readyCtx, readyCancel := context.WithTimeout(ctx, time.Minute)
+		err = poll(readyCtx, func() (bool, error) {
+			_, err := fetchBlockNumber(readyCtx, "http://localhost:8545")
+			return err == nil, err
+		})
+		readyCancel()
+		if err != nil {
+			return fmt.Errorf("reth is not ready: %w", err)
+		}
+
+		r := &replayer{
+			client: &http.Client{Timeout: 30 * time.Second},
+			secret: keys.JWTSecret(),
+			tally:  newReplayTally(),
+		}
+		return r.Run(ctx, engineRecords, rpcRecords)
+	},
+}
+
+// checkReplayOutput refuses to replace an output folder in use by a session or that is
+// not the folder of a previous replay, since the replay removes its files
+func checkReplayOutput(out *output) error {
+	if pid, ok := runningSession(out); ok {
+		return fmt.Errorf("a playground is running in %s (pid %d), use another --output", out.dst, pid)
+	}
+	entries, err := os.ReadDir(out.dst)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(entries) != 0 && !out.Exists(bundleInfoFile) {
+		return fmt.Errorf("%s is not the folder of a replay, use an empty --output", out.dst)
+	}
+	return nil
+}
+
+// replayRecord is a recorded request to the engine api or to the JSON-RPC api
+type replayRecord struct {
+	*rpcRecord
+	engine bool
+}
+
+type replayer struct {
+	client *http.Client
+	secret string
+
+	tally *replayTally
+}
+
+// Run sends the requests in the order in which they were recorded. The engine api requests
+// that only apply to the recorded session (i.e. engine_getPayload) are skipped.
+func (r *replayer) Run(ctx context.Context, engineRecords, rpcRecords []*rpcRecord) error {
+	records := []*replayRecord{}
+	for _, record := range engineRecords {
+		if slices.ContainsFunc(rpcMethods(record.Request), replayedEngineMethod) {
+			records = append(records, &replayRecord{rpcRecord: record, engine: true})
+		}
+	}
+	for _, record := range rpcRecords {
+		records = append(records, &replayRecord{rpcRecord: record})
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("the bundle does not have requests to replay")
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+
+	start := time.Now()
+	first := records[0].Time
+	var lastHead string
+	for i, record := range records {
+		if replaySpeedFlag != 0 {
+			offset := time.Duration(float64(record.Time.Sub(first)) / replaySpeedFlag)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(start.Add(offset))):
+			}
+		}
+
+		var err error
+		if record.engine {
+			err = r.replayEngine(ctx, record.rpcRecord)
+			if head := forkchoiceHead(record.Request); head != "" {
+				lastHead = head
+			}
+		} else {
+			err = r.replayRPC(ctx, record.rpcRecord)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to replay request %d: %w", i+1, err)
+		}
+	}
+
+	fmt.Printf("Replayed %d requests in %s\n", len(records), time.Since(start).Round(time.Second))
+	r.tally.printMethods()
+
+	if lastHead != "" {
+		var head struct {
+			Hash string `json:"hash"`
+		}
+		if err := rpcCall(ctx, "http://localhost:8545", "eth_getBlockByNumber", []interface{}{"latest", false}, &head); err != nil {
+			return fmt.Errorf("failed to get the head block: %w", err)
+		}
+		if !strings.EqualFold(head.Hash, lastHead) {
+			return fmt.Errorf("the head block %s is not the recorded one %s", head.Hash, lastHead)
+		}
+		fmt.Printf("Head block %s matches the recording\n", head.Hash)
+	}
+	return r.tally.Err()
+}
+
+// replayEngine sends a request to the engine api of reth and compares the status of the
+// payload with the recorded one
+func (r *replayer) replayEngine(ctx context.Context, record *rpcRecord) error {
+	var msg struct {
+		Version string            `json:"jsonrpc"`
+		ID      json.RawMessage   `json:"id"`
+		Method  string            `json:"method"`
+		Params  []json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(record.Request, &msg); err != nil {
+		return err
+	}
+	request := record.Request
+	if strings.HasPrefix(msg.Method, "engine_forkchoiceUpdated") && len(msg.Params) > 1 {
+		// without the payload attributes, reth does not build a payload nobody asks for
+		msg.Params[1] = json.RawMessage("null")
+		var err error
+		if request, err = json.Marshal(msg); err != nil {
+			return err
+		}
+	}
+
+	token, err := clproxy.NewJWTToken(r.secret)
+	if err != nil {
+		return err
+	}
+	resp, err := r.post(ctx, "http://localhost:8551", request, token)
+	if err != nil {
+		return err
+	}
+	r.compare([]string{msg.Method}, record, resp, payloadStatus(record.Response) == payloadStatus(resp))
+	return nil
+}
+
+// replayRPC sends a request to the JSON-RPC api of reth and compares the response with
+// the recorded one
+func (r *replayer) replayRPC(ctx context.Context, record *rpcRecord) error {
+	resp, err := r.post(ctx, "http://localhost:8545", record.Request, "")
+	if err != nil {
+		return err
+	}
+	r.compare(rpcMethods(record.Request), record, resp, sameRPCResponse(record.Response, resp))
+	return nil
+}
+
+func (r *replayer) compare(methods []string, record *rpcRecord, resp []byte, same bool) {
+	r.tally.add(methods, same)
+	if !same && replayVerboseFlag {
+		fmt.Printf("Request differs:\n  request:  %s\n  recorded: %s\n  replayed: %s\n", record.Request, record.Response, bytes.TrimSpace(resp))
+	}
+}
+
+func (r *replayer) post(ctx context.Context, url string, data []byte, token string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// replayedEngineMethod returns whether the engine api method changes the chain of reth
+func replayedEngineMethod(method string) bool {
+	return strings.HasPrefix(method, "engine_newPayload") || strings.HasPrefix(method, "engine_forkchoiceUpdated")
+}
+
+// payloadStatus returns the status of the payload in the response of engine_newPayload
+// (the result) or engine_forkchoiceUpdated (the payloadStatus of the result)
+func payloadStatus(response []byte) string {
+	var resp struct {
+		Result struct {
+			Status        string `json:"status"`
+			PayloadStatus struct {
+				Status string `json:"status"`
+			} `json:"payloadStatus"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(response, &resp); err != nil {
+		return ""
+	}
+	if resp.Result.Status != "" {
+		return resp.Result.Status
+	}
+	return resp.Result.PayloadStatus.Status
+}
+
+// forkchoiceHead returns the head block hash of an engine_forkchoiceUpdated request
+func forkchoiceHead(request []byte) string {
+	var msg struct {
+		Method string `json:"method"`
+		Params []struct {
+			HeadBlockHash string `json:"headBlockHash"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal(request, &msg); err != nil || !strings.HasPrefix(msg.Method, "engine_forkchoiceUpdated") || len(msg.Params) == 0 {
+		return ""
+	}
+	return msg.Params[0].HeadBlockHash
+}
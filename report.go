@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// reportLogLines is the number of lines of each log included in the report
+const reportLogLines = 200
+
+var reportFileFlag string
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Create a tar.gz with the information of the playground to attach to a bug report",
+	Long:  `Gather the host and client versions, the endpoints, the last lines of the logs of each service and the recent events of the playground in the output folder into a tar.gz. The keys of the playground are redacted`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		if reportFileFlag == "" {
+			reportFileFlag = fmt.Sprintf("playground-report-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		if err := writeReport(&output{dst: outputFlag}, reportFileFlag); err != nil {
+			return err
+		}
+		fmt.Printf("Report written to %s\n", reportFileFlag)
+		return nil
+	},
+}
+
+// secretAssignmentRe matches the values of variables and flags that look like secrets
+var secretAssignmentRe = regexp.MustCompile(`(?i)((?:secret|token|password|private[_-]?key)[a-z_-]*[=: ]+)\S+`)
+
+// reportRedactor removes the keys of the playground and any value that looks like a secret
+type reportRedactor struct {
+	secrets []string
+}
+
+func newReportRedactor(out *output) *reportRedactor {
+	keys, err := loadKeyRegistry(out)
+	if err != nil {
+		keys = defaultKeyRegistry()
+	}
+	return &reportRedactor{
+		secrets: []string{keys.JWTSecret(), keys.RethP2PKey(), keys.RelaySecretKey()},
+	}
+}
+
+func (r *reportRedactor) Redact(str string) string {
+	for _, secret := range r.secrets {
+		if secret == "" {
+			continue
+		}
+		str = strings.ReplaceAll(str, strings.TrimPrefix(secret, "0x"), "[REDACTED]")
+	}
+	return secretAssignmentRe.ReplaceAllString(str, "${1}[REDACTED]")
+}
+
+func writeReport(out *output, path string) error {
+	redactor := newReportRedactor(out)
+
+	files := map[string]string{
+		"info.txt": reportHostInfo(),
+	}
+
+	logs, err := filepath.Glob(filepath.Join(out.dst, "logs", "*.log"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(logs)
+
+	versions := []string{}
+	for _, logPath := range logs {
+		lines, err := tailFile(logPath, reportLogLines)
+		if err != nil {
+			return err
+		}
+		files[filepath.Join("logs", filepath.Base(logPath))] = strings.Join(lines, "\n")
+
+		name := strings.TrimSuffix(filepath.Base(logPath), ".log")
+		if info, err := loadServiceVersion(out, name); err == nil {
+			versions = append(versions, fmt.Sprintf("%s: %s (%s)", name, info.Version, info.Binary))
+		}
+	}
+	files["versions.txt"] = strings.Join(versions, "\n")
+
+	for _, name := range []string{"endpoints.json", "events.log"} {
+		data, err := out.ReadFile(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		files[name] = string(data)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content := redactor.Redact(files[name])
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		data := []byte(content)
+		hdr := &tar.Header{
+			Name:    filepath.Join("playground-report", name),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func reportHostInfo() string {
+	lines := []string{
+		fmt.Sprintf("created: %s", time.Now().Format(time.RFC3339)),
+		fmt.Sprintf("os/arch: %s/%s", runtime.GOOS, runtime.GOARCH),
+		fmt.Sprintf("cpus: %d", runtime.NumCPU()),
+	}
+	if mem, err := availableMemory(); err == nil {
+		lines = append(lines, fmt.Sprintf("available memory: %d MB", mem>>20))
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		lines = append(lines, fmt.Sprintf("playground: %s (%s)", info.Main.Version, info.GoVersion))
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" || setting.Key == "vcs.modified" {
+				lines = append(lines, fmt.Sprintf("%s: %s", setting.Key, setting.Value))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tailFile(path string, n int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// minimum resources recommended to run the playground with the default settings
+const (
+	minAvailableMemory = 8 << 30
+	minCPUs            = 4
+)
+
+// lowResourcesArtifact marks that the chain in the output folder was created
+// with the lighter settings, which must be kept when the chain is continued.
+const lowResourcesArtifact = "low_resources"
+
+// lowResourcesReason returns why the host is below the recommended resources
+// or an empty string if it has enough resources.
+func lowResourcesReason() string {
+	reasons := []string{}
+	if runtime.NumCPU() < minCPUs {
+		reasons = append(reasons, fmt.Sprintf("%d CPUs (recommended %d)", runtime.NumCPU(), minCPUs))
+	}
+	if mem, err := availableMemory(); err == nil && mem < minAvailableMemory {
+		reasons = append(reasons, fmt.Sprintf("%d MB of available memory (recommended %d MB)", mem>>20, minAvailableMemory>>20))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// availableMemory returns the memory available in the host. On macOS, it returns the
+// total memory since the available memory is not reported in a reliable way.
+func availableMemory() (uint64, error) {
+	switch runtime.GOOS {
+	case "linux":
+		file, err := os.Open("/proc/meminfo")
+		if err != nil {
+			return 0, err
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			// MemAvailable:   12345678 kB
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 3 && fields[0] == "MemAvailable:" {
+				kb, err := strconv.ParseUint(fields[1], 10, 64)
+				if err != nil {
+					return 0, err
+				}
+				return kb << 10, nil
+			}
+		}
+		return 0, fmt.Errorf("MemAvailable not found in /proc/meminfo")
+	case "darwin":
+		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	default:
+		return 0, fmt.Errorf("unsupported os %s", runtime.GOOS)
+	}
+}
+
+var serviceResourcesFlag []string
+
+// serviceResourceLimits are the limits of --service-resources by service
+var serviceResourceLimits map[string]*serviceResources
+
+// serviceResources are the cpu and memory limits of a service. A zero value is no limit.
+type serviceResources struct {
+	cpus   float64
+	memory uint64
+}
+
+// WithResources limits the cpus (i.e. 1.5) and the memory (in bytes) of the service.
+// The limits of --service-resources take precedence.
+func (s *service) WithResources(cpus float64, memory uint64) *service {
+	s.resources = &serviceResources{cpus: cpus, memory: memory}
+	return s
+}
+
+// parseServiceResources parses the limits of the form <service>=<cpus>:<memory>,
+// where any of the two can be empty (i.e. reth=2:4GB or beacon_node=:2GB)
+func parseServiceResources(items []string) (map[string]*serviceResources, error) {
+	limits := map[string]*serviceResources{}
+	for _, item := range items {
+		name, spec, ok := strings.Cut(item, "=")
+		cpusStr, memoryStr, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 || name == "" || (cpusStr == "" && memoryStr == "") {
+			return nil, fmt.Errorf("invalid resources '%s', expected <service>=<cpus>:<memory>", item)
+		}
+
+		res := &serviceResources{}
+		if cpusStr != "" {
+			cpus, err := strconv.ParseFloat(cpusStr, 64)
+			if err != nil || cpus <= 0 {
+				return nil, fmt.Errorf("invalid cpus '%s' of %s", cpusStr, name)
+			}
+			res.cpus = cpus
+		}
+		if memoryStr != "" {
+			memory, err := parseSize(memoryStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory of %s: %w", name, err)
+			}
+			res.memory = memory
+		}
+		limits[name] = res
+	}
+
+	if len(limits) != 0 {
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return nil, fmt.Errorf("the resource limits of the services require systemd-run (Linux with systemd)")
+		}
+	}
+	return limits, nil
+}
+
+// wrapArgs runs the command in a transient systemd scope with the limits. systemd-run
+// replaces itself with the command, so the pid of the service does not change.
+func (r *serviceResources) wrapArgs(args []string) []string {
+	wrapped := []string{"systemd-run"}
+	if os.Geteuid() != 0 {
+		wrapped = append(wrapped, "--user")
+	}
+	wrapped = append(wrapped, "--scope", "--quiet", "--collect")
+	if r.cpus != 0 {
+		wrapped = append(wrapped, "-p", fmt.Sprintf("CPUQuota=%d%%", int(r.cpus*100)))
+	}
+	if r.memory != 0 {
+		wrapped = append(wrapped, "-p", fmt.Sprintf("MemoryMax=%d", r.memory))
+	}
+	wrapped = append(wrapped, "--")
+	return append(wrapped, args...)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// sessionArtifact identifies the session running in the output folder
+const sessionArtifact = "session.json"
+
+var reuseFlag bool
+
+// sessionFlagsHash is the hash of the flags of the session being started
+var sessionFlagsHash string
+
+// flagsIgnoredByReuse do not change the chain, so a session started with different
+// values can still be reused
+var flagsIgnoredByReuse = map[string]bool{
+	"reuse":           true,
+	"continue":        true,
+	"output":          true,
+	"progress-format": true,
+	"artifacts-cache": true,
+}
+
+type sessionInfo struct {
+	ID        string    `json:"id"`
+	FlagsHash string    `json:"flags_hash"`
+	Started   time.Time `json:"started"`
+
+	// Chain is the public network synced by the session or devnet for the local devnet
+	Chain    string `json:"chain"`
+	CLClient string `json:"cl_client"`
+}
+
+// flagsHash returns a hash of the values of the flags (including the defaults)
+func flagsHash(flags *pflag.FlagSet) string {
+	values := []string{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		if !flagsIgnoredByReuse[f.Name] {
+			values = append(values, f.Name+"="+f.Value.String())
+		}
+	})
+	sort.Strings(values)
+
+	hash := sha256.Sum256([]byte(strings.Join(values, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// runningSession returns the pid of the playground running in the output folder, if any
+func runningSession(out *output) (int, bool) {
+	pid, _, running := readPidFile(filepath.Join(out.dst, playgroundPidArtifact))
+	return pid, running && pid != os.Getpid()
+}
+
+// reuseSession prints the endpoints of the playground running in the output folder if it
+// was started with the same flags. It returns false if there is no playground running.
+func reuseSession() (bool, error) {
+	if err := resolveOutputFlag(); err != nil {
+		return false, err
+	}
+	out := &output{dst: outputFlag}
+
+	pid, ok := runningSession(out)
+	if !ok {
+		return false, nil
+	}
+
+	var info sessionInfo
+	data, err := out.ReadFile(sessionArtifact)
+	if err == nil {
+		err = json.Unmarshal(data, &info)
+	}
+	if err != nil || info.FlagsHash != sessionFlagsHash {
+		return false, fmt.Errorf("the playground running in %s (pid %d) was started with different flags, stop it first", out.dst, pid)
+	}
+
+	endpoints, err := loadEndpoints(out)
+	if err != nil {
+		return false, err
+	}
+	fmt.Printf("Reusing the playground running in %s (pid %d)\n", out.dst, pid)
+	fmt.Printf("Session: %s\n\n", info.ID)
+
+	names := []string{}
+	for name := range endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Services:\n==================\n")
+	for _, name := range names {
+		ports := []string{}
+		for port, url := range endpoints[name] {
+			ports = append(ports, fmt.Sprintf("%s: %s", port, url))
+		}
+		sort.Strings(ports)
+		fmt.Printf("- %s (%s)\n", name, strings.Join(ports, ", "))
+	}
+	return true, nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// rpcRecorderPort is the port of the proxy that records the requests to the EL
+const rpcRecorderPort = 8547
+
+// rpcRecordingArtifact is the file of the output folder with the recorded requests
+const rpcRecordingArtifact = "rpc_recording.jsonl"
+
+var recordRPCFlag bool
+
+// rpcRecord is a request to the EL and its response. The recording has one record
+// per line.
+type rpcRecord struct {
+	Time     time.Time       `json:"time"`
+	Duration int64           `json:"duration_ms"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// rpcRecorder is a JSON-RPC proxy that forwards the requests to the EL and records
+// them along with the responses.
+type rpcRecorder struct {
+	target string
+	client *http.Client
+
+	lock sync.Mutex
+	file *os.File
+}
+
+func newRPCRecorder(target string, path string) (*rpcRecorder, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := &rpcRecorder{
+		target: target,
+		client: &http.Client{Timeout: 30 * time.Second},
+		file:   file,
+	}
+	return r, nil
+}
+
+func (r *rpcRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	resp, err := r.client.Post(r.target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+
+	// only the valid JSON-RPC exchanges can be replayed
+	if !json.Valid(body) || !json.Valid(respBody) {
+		return
+	}
+	record := &rpcRecord{
+		Time:     start,
+		Duration: time.Since(start).Milliseconds(),
+		Request:  body,
+		Response: respBody,
+	}
+	if err := r.write(record); err != nil {
+		logger.Error("Error recording the rpc request", "err", err)
+	}
+}
+
+func (r *rpcRecorder) write(record *rpcRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, err = r.file.Write(append(data, '\n'))
+	return err
+}
+
+func (r *rpcRecorder) Server(port int) *http.Server {
+	return &http.Server{
+		Addr:    fmt.Sprintf("127.0.0.1:%d", port),
+		Handler: r,
+	}
+}
+
+func (r *rpcRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.file.Close()
+}
+
+func readRPCRecording(path string) ([]*rpcRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records := []*rpcRecord{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record rpcRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid record %d: %w", len(records)+1, err)
+		}
+		records = append(records, &record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// rpcMethods returns the methods of the request, which is either a single call or a batch
+func rpcMethods(request json.RawMessage) []string {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var batch []call
+	if err := json.Unmarshal(request, &batch); err != nil {
+		var single call
+		if err := json.Unmarshal(request, &single); err != nil {
+			return []string{"unknown"}
+		}
+		batch = []call{single}
+	}
+	methods := []string{}
+	for _, c := range batch {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
+
+// sameRPCResponse compares two responses regardless of the formatting of the json
+func sameRPCResponse(a, b json.RawMessage) bool {
+	var objA, objB interface{}
+	if err := json.Unmarshal(a, &objA); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &objB); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(objA, objB)
+}
+
+// replayTally counts the replayed requests and the ones with a different response than the
+// recorded one, per method
+type replayTally struct {
+	numRequests int
+	numDiffs    int
+
+	total map[string]int
+	diffs map[string]int
+}
+
+func newReplayTally() *replayTally {
+	return &replayTally{total: map[string]int{}, diffs: map[string]int{}}
+}
+
+// add counts a replayed request with the methods of its calls
+func (t *replayTally) add(methods []string, same bool) {
+	t.numRequests++
+	if !same {
+		t.numDiffs++
+	}
+	for _, method := range methods {
+		t.total[method]++
+		if !same {
+			t.diffs[method]++
+		}
+	}
+}
+
+// printMethods prints the number of requests and different responses of each method
+func (t *replayTally) printMethods() {
+	methods := []string{}
+	for method := range t.total {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	for _, method := range methods {
+		fmt.Printf("- %s: %d requests, %d different\n", method, t.total[method], t.diffs[method])
+	}
+}
+
+// Err returns an error if any response is different
+func (t *replayTally) Err() error {
+	if t.numDiffs != 0 {
+		return fmt.Errorf("%d of %d responses are different", t.numDiffs, t.numRequests)
+	}
+	return nil
+}
+
+var rpcCmd = &cobra.Command{
+	Use:   "rpc",
+	Short: "Tools for the JSON-RPC traffic of the EL",
+}
+
+var rpcReplayFileFlag string
+var rpcReplayTargetFlag string
+var rpcReplayVerboseFlag bool
+
+var rpcReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay the recorded JSON-RPC requests against an EL",
+	Long:  `Send the requests recorded with --record-rpc to an EL (i.e. another session or version of the client) and compare the responses with the recorded ones`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := rpcReplayFileFlag
+		if path == "" {
+			if err := resolveOutputFlag(); err != nil {
+				return err
+			}
+			path = filepath.Join(outputFlag, rpcRecordingArtifact)
+		}
+		records, err := readRPCRecording(path)
+		if err != nil {
+			return fmt.Errorf("failed to read the recording: %w", err)
+		}
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		tally := newReplayTally()
+
+		for i, record := range records {
+			resp, err := client.Post(rpcReplayTargetFlag, "application/json", bytes.NewReader(record.Request))
+			if err != nil {
+				return fmt.Errorf("failed to replay request %d: %w", i+1, err)
+			}
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return fmt.Errorf("failed to replay request %d: %w", i+1, err)
+			}
+
+			same := sameRPCResponse(record.Response, respBody)
+			tally.add(rpcMethods(record.Request), same)
+			if !same && rpcReplayVerboseFlag {
+				fmt.Printf("Request %d differs:\n  request:  %s\n  recorded: %s\n  replayed: %s\n", i+1, record.Request, record.Response, bytes.TrimSpace(respBody))
+			}
+		}
+
+		fmt.Printf("Replayed %d requests against %s\n", len(records), rpcReplayTargetFlag)
+		tally.printMethods()
+		return tally.Err()
+	},
+}
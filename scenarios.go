@@ -0,0 +1,439 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	mevboostrelay "github.com/ferranbt/builder-playground/mev-boost-relay"
+	mevRCommon "github.com/flashbots/mev-boost-relay/common"
+	"github.com/spf13/cobra"
+)
+
+var junitFlag string
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Start the playground and run the test scenarios against it",
+	Long:  `Start the playground, run a set of scenarios (actions and assertions) against the chain and stop it. The results can be written as a JUnit XML report`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTests(cmd.Context())
+	},
+}
+
+// scenario is a list of steps (actions and assertions) that are run in order.
+// The scenario fails on the first step that fails.
+type scenario struct {
+	name  string
+	steps []*scenarioStep
+}
+
+type scenarioStep struct {
+	name    string
+	timeout time.Duration
+	run     func(ctx context.Context) error
+}
+
+// defaultScenarios are the scenarios that every playground run has to pass
+var defaultScenarios = []*scenario{
+	{
+		name: "chain-progresses",
+		steps: []*scenarioStep{
+			{name: "wait 5 blocks", timeout: 2 * time.Minute, run: waitBlocksStep(5)},
+		},
+	},
+	{
+		name: "transaction-included",
+		steps: []*scenarioStep{
+			{name: "send transaction", timeout: time.Minute, run: sendTxStep},
+		},
+	},
+	{
+		name: "payloads-delivered",
+		steps: []*scenarioStep{
+			{name: "wait relay bids", timeout: 5 * time.Minute, run: readinessStep("mev-boost-relay", mevboostrelay.StageFirstBid)},
+			{name: "wait payload delivered", timeout: 5 * time.Minute, run: payloadDeliveredStep},
+		},
+	},
+	{
+		name: "services-healthy",
+		steps: []*scenarioStep{
+			{name: "check services", timeout: 30 * time.Second, run: servicesHealthyStep},
+		},
+	},
+}
+
+type scenarioResult struct {
+	scenario *scenario
+	duration time.Duration
+	err      error
+}
+
+func runTests(ctx context.Context) error {
+	results, err := runSessionScenarios(ctx)
+	if err != nil {
+		return err
+	}
+
+	if junitFlag != "" {
+		if err := writeJUnitReport(junitFlag, newJUnitTestSuite("playground", results)); err != nil {
+			return err
+		}
+	}
+	if failed := numFailed(results); failed != 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(results))
+	}
+	return nil
+}
+
+func numFailed(results []*scenarioResult) int {
+	failed := 0
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// runSessionScenarios starts the playground, runs the default scenarios against it and stops it
+func runSessionScenarios(ctx context.Context) ([]*scenarioResult, error) {
+	sess, err := startSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Stop()
+
+	// abort the scenarios if any of the services fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-sess.svcManager.NotifyErrCh():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// the chain does not produce blocks until the genesis time
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(time.Duration(genesisDelayFlag) * time.Second):
+	}
+
+	scenarios := slices.Clone(defaultScenarios)
+	if sess.svcManager.relay != nil {
+		scenarios = append(scenarios, builderFallbackScenario(sess.svcManager.relay))
+	}
+
+	results := []*scenarioResult{}
+	for _, s := range scenarios {
+		fmt.Printf("Running scenario %s\n", s.name)
+
+		res := runScenario(ctx, s)
+		if res.err != nil {
+			fmt.Printf("- FAIL %s (%s): %v\n", s.name, res.duration.Round(time.Millisecond), res.err)
+		} else {
+			fmt.Printf("- PASS %s (%s)\n", s.name, res.duration.Round(time.Millisecond))
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func runScenario(ctx context.Context, s *scenario) *scenarioResult {
+	now := time.Now()
+	res := &scenarioResult{scenario: s}
+
+	for _, step := range s.steps {
+		stepCtx, cancel := context.WithTimeout(ctx, step.timeout)
+		err := step.run(stepCtx)
+		cancel()
+
+		if err != nil {
+			res.err = fmt.Errorf("step '%s': %w", step.name, err)
+			break
+		}
+	}
+	res.duration = time.Since(now)
+	return res
+}
+
+// poll calls fn periodically until it returns true or the context is done
+func poll(ctx context.Context, fn func() (bool, error)) error {
+	var lastErr error
+	for {
+		done, err := fn()
+		if err == nil && done {
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return fmt.Errorf("%w: %v", ctx.Err(), lastErr)
+			}
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func getBlockNumber(ctx context.Context) (uint64, error) {
+	var blockNumber string
+	if err := rpcCall(ctx, "http://localhost:8545", "eth_blockNumber", nil, &blockNumber); err != nil {
+		return 0, err
+	}
+	var num big.Int
+	if _, ok := num.SetString(blockNumber, 0); !ok {
+		return 0, fmt.Errorf("invalid block number '%s'", blockNumber)
+	}
+	return num.Uint64(), nil
+}
+
+// waitBlocksStep waits until the chain has progressed the given number of blocks
+func waitBlocksStep(numBlocks uint64) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var start *uint64
+		return poll(ctx, func() (bool, error) {
+			num, err := getBlockNumber(ctx)
+			if err != nil {
+				return false, err
+			}
+			if start == nil {
+				start = &num
+			}
+			return num >= *start+numBlocks, nil
+		})
+	}
+}
+
+// sendTxStep sends a transfer from the first prefunded account and waits for its receipt
+func sendTxStep(ctx context.Context) error {
+	clt, err := ethclient.DialContext(ctx, "http://localhost:8545")
+	if err != nil {
+		return err
+	}
+	defer clt.Close()
+
+	priv, err := getPrivKey(prefundedAccounts[0])
+	if err != nil {
+		return err
+	}
+	addr := ecrypto.PubkeyToAddress(priv.PublicKey)
+
+	chainID, err := clt.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+	nonce, err := clt.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := clt.SuggestGasPrice(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := types.SignNewTx(priv, types.LatestSignerForChainID(chainID), &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &addr,
+		Value:    big.NewInt(1),
+		Gas:      21000,
+		GasPrice: gasPrice,
+	})
+	if err != nil {
+		return err
+	}
+	if err := clt.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+
+	return poll(ctx, func() (bool, error) {
+		receipt, err := clt.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return false, err
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			return false, fmt.Errorf("transaction %s reverted", tx.Hash())
+		}
+		return true, nil
+	})
+}
+
+// readinessStep waits until the service reaches the readiness stage
+func readinessStep(service, stage string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		out := &output{dst: outputFlag}
+		return poll(ctx, func() (bool, error) {
+			return out.Exists(readinessArtifact(service, stage)), nil
+		})
+	}
+}
+
+// payloadDeliveredStep waits until the relay has delivered at least one payload
+func payloadDeliveredStep(ctx context.Context) error {
+	return poll(ctx, func() (bool, error) {
+		payloads, err := getProposerPayloadDelivered(ctx)
+		if err != nil {
+			return false, err
+		}
+		return len(payloads) != 0, nil
+	})
+}
+
+// servicesHealthyStep checks that all the services report as healthy
+func servicesHealthyStep(ctx context.Context) error {
+	return poll(ctx, func() (bool, error) {
+		for _, svc := range collectHealthSummary(ctx, nil).services {
+			if svc.err != nil {
+				return false, fmt.Errorf("%s is unhealthy: %v", svc.name, svc.err)
+			}
+		}
+		return true, nil
+	})
+}
+
+// builderFallbackScenario makes the relay fail the getHeader requests of some slots, with
+// errors and late headers, and checks that the proposers fall back to local blocks
+func builderFallbackScenario(relay *mevboostrelay.MevBoostRelay) *scenario {
+	var slots []uint64
+	return &scenario{
+		name: "builder-fallback",
+		steps: []*scenarioStep{
+			{name: "wait payload delivered", timeout: 5 * time.Minute, run: payloadDeliveredStep},
+			{name: "inject relay faults", timeout: 30 * time.Second, run: func(ctx context.Context) error {
+				var err error
+				slots, err = injectRelayFaultsStep(ctx, relay)
+				return err
+			}},
+			{name: "check local blocks", timeout: 2 * time.Minute, run: func(ctx context.Context) error {
+				return localBlocksStep(ctx, slots)
+			}},
+		},
+	}
+}
+
+// injectRelayFaultsStep injects faults in the next slots, alternating errors and late
+// headers, and returns the slots
+func injectRelayFaultsStep(ctx context.Context, relay *mevboostrelay.MevBoostRelay) ([]uint64, error) {
+	clock, err := fetchSlotClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// leave a slot of margin for the proposer of the next one
+	first := clock.Slot(time.Now()) + 2
+
+	faults := []*mevboostrelay.Fault{
+		{Kind: mevboostrelay.FaultError},
+		{Kind: mevboostrelay.FaultLateHeader, Delay: 3 * time.Second},
+		{Kind: mevboostrelay.FaultError},
+		{Kind: mevboostrelay.FaultLateHeader, Delay: 3 * time.Second},
+	}
+	slots := []uint64{}
+	for i, fault := range faults {
+		slot := first + uint64(i)
+		if err := relay.InjectFault(slot, fault); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// localBlocksStep waits until the slots have passed and checks that all of them have a
+// block that was not delivered by the relay
+func localBlocksStep(ctx context.Context, slots []uint64) error {
+	last := slots[len(slots)-1]
+	if err := poll(ctx, func() (bool, error) {
+		head, err := fetchHeadSlot(ctx, "http://localhost:3500")
+		if err != nil {
+			return false, err
+		}
+		return head > last, nil
+	}); err != nil {
+		return err
+	}
+
+	payloads, err := getProposerPayloadDelivered(ctx)
+	if err != nil {
+		return err
+	}
+	for _, slot := range slots {
+		if slices.ContainsFunc(payloads, func(p *mevRCommon.BidTraceV2JSON) bool { return p.Slot == slot }) {
+			return fmt.Errorf("the relay delivered the payload of slot %d with a fault", slot)
+		}
+		var header json.RawMessage
+		if err := httpGetJSON(ctx, fmt.Sprintf("http://localhost:3500/eth/v1/beacon/headers/%d", slot), &header); err != nil {
+			return fmt.Errorf("slot %d has no block, the proposer did not fall back to a local block: %w", slot, err)
+		}
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Cases    []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+func newJUnitTestSuite(name string, results []*scenarioResult) *junitTestSuite {
+	suite := &junitTestSuite{
+		Name: name,
+	}
+
+	var total time.Duration
+	for _, res := range results {
+		testCase := &junitTestCase{
+			Name:      res.scenario.name,
+			ClassName: name,
+			Time:      fmt.Sprintf("%.3f", res.duration.Seconds()),
+		}
+		if res.err != nil {
+			testCase.Failure = &junitFailure{Message: res.err.Error()}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+		total += res.duration
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+	return suite
+}
+
+func writeJUnitReport(path string, suites ...*junitTestSuite) error {
+	data, err := xml.MarshalIndent(&junitTestSuites{Suites: suites}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
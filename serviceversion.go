@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// versionArtifact records the binary of the service and its version when it starts
+func versionArtifact(name string) string {
+	return filepath.Join("versions", name+".json")
+}
+
+type serviceVersion struct {
+	Binary  string `json:"binary"`
+	Version string `json:"version"`
+}
+
+// recordVersion writes the binary that runs the service and the version it reports. The
+// binary is the one of the arguments of the service, not the command that wraps it (i.e.
+// systemd-run when its resources are limited).
+func (s *serviceManager) recordVersion(ss *service) {
+	binary := ss.args[0]
+	if path, err := exec.LookPath(binary); err == nil {
+		binary = path
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		info := &serviceVersion{Binary: binary, Version: binaryVersion(s.ctx, binary)}
+		if err := s.out.WriteFile(versionArtifact(ss.name), info); err != nil {
+			logger.Error("Error writing the version of the service", "service", ss.name, "err", err)
+		}
+	}()
+}
+
+// binaryVersion returns the first line of the output of --version of the binary, or an
+// empty string if it does not have one
+func binaryVersion(ctx context.Context, binary string) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := exec.CommandContext(ctx, binary, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.SplitN(string(res), "\n", 2)[0])
+}
+
+// loadServiceVersion returns the binary and version recorded when the service started
+func loadServiceVersion(out *output, name string) (*serviceVersion, error) {
+	data, err := out.ReadFile(versionArtifact(name))
+	if err != nil {
+		return nil, err
+	}
+	var info serviceVersion
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
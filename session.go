@@ -0,0 +1,384 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	relayloadgen "github.com/ferranbt/builder-playground/relay-loadgen"
+	"github.com/hashicorp/go-uuid"
+)
+
+// session is a running instance of the playground
+type session struct {
+	id         string
+	out        *output
+	svcManager *serviceManager
+
+	// diskQuota is the maximum size of the output folder, 0 if there is no quota
+	diskQuota uint64
+
+	// freezeAt is the condition to pause the services, nil without --freeze-at
+	freezeAt *freezeCondition
+}
+
+// resolveOutputFlag sets the default output folder if --output is not set
+func resolveOutputFlag() error {
+	if outputFlag == "" {
+		// Use the $HOMEDIR/devnet as the default output
+		homeDir, err := getHomeDir()
+		if err != nil {
+			return err
+		}
+		outputFlag = filepath.Join(homeDir, "devnet")
+	}
+	return nil
+}
+
+// startSession creates the artifacts of the chain and starts all the services
+func startSession(ctx context.Context) (*session, error) {
+	if genesisDelayFlag < minimumGenesisDelay {
+		return nil, fmt.Errorf("genesis delay must be at least %d", minimumGenesisDelay)
+	}
+	if numELNodesFlag < 1 {
+		return nil, fmt.Errorf("--num-el-nodes must be at least 1")
+	}
+	if numELNodesFlag > 1 && networkFlag != "" {
+		return nil, fmt.Errorf("--num-el-nodes cannot be used with --network")
+	}
+	if numValidatorsFlag == 0 {
+		return nil, fmt.Errorf("--num-validators must be at least 1")
+	}
+	if numValidatorsFlag != defaultNumValidators && networkFlag != "" {
+		return nil, fmt.Errorf("--num-validators cannot be used with --network, there are no local validators")
+	}
+	if prefundedBalanceFlag != "" {
+		if _, err := parseBalance(prefundedBalanceFlag); err != nil {
+			return nil, fmt.Errorf("invalid --prefunded-balance: %w", err)
+		}
+	}
+	if err := validateCLClient(); err != nil {
+		return nil, err
+	}
+	if err := validateValidatorSplit(); err != nil {
+		return nil, err
+	}
+	if startSlotFlag != 0 && networkFlag != "" {
+		return nil, fmt.Errorf("--start-slot cannot be used with --network")
+	}
+	if startSlotFlag > maxStartSlot {
+		return nil, fmt.Errorf("--start-slot must be at most %d, the beacon node processes every empty slot before it at startup", maxStartSlot)
+	}
+	if len(mockBuilders()) != 0 {
+		if _, err := relayloadgen.ParseValueDistribution(relayLoadgenValueFlag); err != nil {
+			return nil, fmt.Errorf("invalid --relay-loadgen-value: %w", err)
+		}
+	}
+	if clProxyCompareFlag && secondaryBuilderPort == 0 {
+		return nil, fmt.Errorf("--cl-proxy-compare requires a --secondary builder")
+	}
+	var diskQuota uint64
+	if maxDiskFlag != "" {
+		var err error
+		if diskQuota, err = parseSize(maxDiskFlag); err != nil {
+			return nil, fmt.Errorf("invalid --max-disk: %w", err)
+		}
+	}
+	limits, err := parseServiceResources(serviceResourcesFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --service-resources: %w", err)
+	}
+	serviceResourceLimits = limits
+	overrides, err := parseOverrides(overrideFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --override: %w", err)
+	}
+	serviceOverrides = overrides
+	skews, err := parseSkews(skewFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --skew: %w", err)
+	}
+	serviceSkews = skews
+	chaos, err := parseChaos(chaosFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --chaos: %w", err)
+	}
+	serviceChaos = chaos
+	if err := validateServiceNames(); err != nil {
+		return nil, err
+	}
+	builds, err := parseSourceBuilds(buildFromSourceFlag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --build-from-source: %w", err)
+	}
+	for _, b := range builds {
+		if !slices.Contains(releaseNames(), b.name) {
+			return nil, fmt.Errorf("release '%s' in --build-from-source is not used with these flags", b.name)
+		}
+	}
+	sourceBuilds = builds
+	var freezeAt *freezeCondition
+	if freezeAtFlag != "" {
+		if freezeAt, err = parseFreezeCondition(freezeAtFlag); err != nil {
+			return nil, fmt.Errorf("invalid --freeze-at: %w", err)
+		}
+	} else if freezeSnapshotFlag {
+		return nil, fmt.Errorf("--freeze-snapshot requires --freeze-at")
+	}
+	if networkFlag != "" && checkpointSyncURLFlag == "" {
+		url, ok := checkpointSyncURLs[networkFlag]
+		if !ok {
+			return nil, fmt.Errorf("no default checkpoint sync url for network '%s', use --checkpoint-sync-url", networkFlag)
+		}
+		checkpointSyncURLFlag = url
+	}
+
+	if err := resolveOutputFlag(); err != nil {
+		return nil, err
+	}
+	if err := resolveFeatures(); err != nil {
+		return nil, err
+	}
+	if err := setupProgress(); err != nil {
+		return nil, err
+	}
+	if err := setupLogging(); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &output{dst: outputFlag}
+	if pid, ok := runningSession(out); ok {
+		return nil, fmt.Errorf("a playground is already running in %s (pid %d), stop it or run with --reuse to use it", outputFlag, pid)
+	}
+
+	fmt.Printf("Session: %s\n", sessionID)
+	fmt.Printf("Output directory: %s\n", outputFlag)
+
+	sess := &session{
+		id:        sessionID,
+		out:       out,
+		diskQuota: diskQuota,
+		freezeAt:  freezeAt,
+	}
+	if err := sess.start(ctx); err != nil {
+		// close all services if there was an error
+		sess.Stop()
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *session) start(ctx context.Context) error {
+	out := s.out
+
+	var (
+		keys     *keyRegistry
+		snapshot *genesisSnapshot
+	)
+
+	// the chain of the snapshot continues in the output folder
+	if fromSnapshotFlag != "" {
+		if err := restoreSnapshot(fromSnapshotFlag, out); err != nil {
+			return err
+		}
+		continueFlag = true
+	}
+
+	exists := out.Exists("")
+	if exists && continueFlag {
+		logger.Info("Artifacts already exist, continuing...")
+
+		// the output folder might have been created by an older version
+		if err := migrateOutput(out); err != nil {
+			return err
+		}
+
+		var err error
+		if keys, err = loadKeyRegistry(out); err != nil {
+			return err
+		}
+
+		// the chain has to continue with the same settings it was created with
+		if out.Exists(lowResourcesArtifact) {
+			features.Enable(featureLowResources)
+			logger.Info("Chain created with low resources settings, continuing with them")
+		}
+	} else {
+		if exists {
+			logger.Info("Artifacts already exist, resetting them...")
+
+			// Keep the genesis of the previous run to regenerate the new one faster
+			var err error
+			if snapshot, err = loadGenesisSnapshot(out); err != nil && !os.IsNotExist(err) {
+				logger.Warn("Could not load the genesis of the previous run", "err", err)
+			}
+
+			// Remove the current artifacts and create new ones
+			if err := out.Remove(""); err != nil {
+				return err
+			}
+		}
+
+		if uniqueKeysFlag {
+			var err error
+			if keys, err = newRandomKeyRegistry(); err != nil {
+				return err
+			}
+		} else {
+			keys = defaultKeyRegistry()
+		}
+
+		if reason := lowResourcesReason(); reason != "" {
+			if noDegradeFlag {
+				logger.Warn("The host has low resources", "reason", reason)
+			} else {
+				logger.Warn("The host has low resources, running reth as a pruned node with less logging (disable with --no-degrade)", "reason", reason)
+				features.Enable(featureLowResources)
+			}
+		}
+		if err := out.WriteFile(layoutArtifact, newOutputLayout()); err != nil {
+			return err
+		}
+		if features.Enabled(featureLowResources) {
+			if err := out.WriteFile(lowResourcesArtifact, ""); err != nil {
+				return err
+			}
+		}
+
+		if networkFlag != "" {
+			// the genesis artifacts of a public network are already known by the clients
+			logger.Info("Syncing public network", "network", networkFlag)
+			if err := out.WriteBatch(keys.Artifacts()); err != nil {
+				return err
+			}
+		} else {
+			if err := setupArtifacts(keys, snapshot); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// the pid of the playground is used by the other commands (i.e. status)
+	if err := out.WriteFile(playgroundPidArtifact, strconv.Itoa(os.Getpid())); err != nil {
+		return err
+	}
+	chain := networkFlag
+	if chain == "" {
+		chain = "devnet"
+	}
+	info := &sessionInfo{ID: s.id, FlagsHash: sessionFlagsHash, Started: time.Now(), Chain: chain, CLClient: clClientFlag}
+	if err := out.WriteFile(sessionArtifact, info); err != nil {
+		return err
+	}
+	if err := registerSession(s.id, out); err != nil {
+		logger.Error("Error registering the session", "err", err)
+	}
+	s.svcManager = newServiceManager(ctx, out)
+	if err := setupServices(s.svcManager, out, keys); err != nil {
+		return err
+	}
+	if err := writeOutputSummary(s.id, out, keys); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputSummaryArtifact, err)
+	}
+	if err := startChaos(out, s.svcManager); err != nil {
+		return err
+	}
+
+	// This is not the most efficient solution since we are querying the endpoint for the full list of payloads
+	// every 2 seconds. It should be fine for the kind of workloads expected to run.
+	s.svcManager.NewCronJob("watch-payloads", 2*time.Second, newProposerPayloadsWatcher())
+	s.svcManager.NewCronJob("disk-usage", 10*time.Second, newDiskWatcher(out, s.diskQuota, s.svcManager.emitError))
+	if s.freezeAt != nil {
+		s.svcManager.NewCronJob("freeze", time.Second, newFreezeWatcher(out, s.freezeAt))
+	}
+	if progress != nil {
+		s.svcManager.NewCronJob("progress-ready", time.Second, newReadyWatcher())
+	}
+	return nil
+}
+
+// Stop stops all the services and uploads the artifacts if requested
+func (s *session) Stop() {
+	emitProgress(&progressEvent{Type: progressStopping})
+	if s.svcManager != nil {
+		s.svcManager.StopAndWait()
+	}
+	stopChaos(s.out)
+	s.out.Remove(playgroundPidArtifact)
+	s.out.Remove(sessionArtifact)
+	unregisterSession(s.id)
+	emitProgress(&progressEvent{Type: progressStopped})
+
+	if uploadArtifactsFlag != "" {
+		if err := uploadArtifacts(uploadArtifactsFlag, s.id, uploadArtifactsRetentionFlag, s.out); err != nil {
+			logger.Error("Error uploading the artifacts", "err", err)
+		}
+	}
+}
+
+// processServiceNames returns the names of the services that run a process with the
+// current flags, so they are known before the services start. The in-process services
+// are not included, they have no process to limit, override, skew or pause.
+func processServiceNames() []string {
+	names := []string{"reth"}
+	for _, f := range rethFollowers() {
+		names = append(names, f.name)
+	}
+	names = append(names, "beacon_node")
+	// there are no local validators in a public network
+	if networkFlag == "" {
+		for _, vc := range validatorClients() {
+			if vc.client == clPrysm {
+				names = append(names, vc.importJob())
+			}
+			names = append(names, vc.name)
+		}
+	}
+	return names
+}
+
+// validateServiceNames checks that the services of the per-service flags exist, so a
+// typo fails before the binaries are downloaded and the services start
+func validateServiceNames() error {
+	names := processServiceNames()
+	check := func(flag, name string) error {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown service '%s' in %s, the services are %s", name, flag, strings.Join(names, ", "))
+		}
+		return nil
+	}
+	for name := range serviceResourceLimits {
+		if err := check("--service-resources", name); err != nil {
+			return err
+		}
+	}
+	for name := range serviceOverrides {
+		if err := check("--override", name); err != nil {
+			return err
+		}
+	}
+	for name := range serviceSkews {
+		if err := check("--skew", name); err != nil {
+			return err
+		}
+	}
+	for name := range serviceChaos {
+		if err := check("--chaos", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var envShellFlag bool
+
+// endpointVars are the environment variables of the env command and the endpoint
+// (service and port) of each one
+var endpointVars = map[string][2]string{
+	"ETH_RPC_URL":    {"reth", "http"},
+	"ENGINE_API_URL": {"reth", "authrpc"},
+	"BEACON_API_URL": {"beacon_node", "http"},
+	"RELAY_URL":      {"mev-boost-relay", "http"},
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print the endpoints of the playground as environment variables",
+	Long:  `Print the export statements of the endpoints of the playground running in the output folder (ETH_RPC_URL, ENGINE_API_URL, BEACON_API_URL, RELAY_URL), the path of the jwt secret (JWT_PATH) and a prefunded key (PRIVATE_KEY), to use with eval. With --shell, start a shell with them instead.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		vars, err := playgroundEnv(out)
+		if err != nil {
+			return err
+		}
+
+		if !envShellFlag {
+			names := []string{}
+			for name := range vars {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("export %s=%s\n", name, shellQuote(vars[name]))
+			}
+			return nil
+		}
+
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		fmt.Printf("Starting %s with the endpoints of %s, exit to return\n", shell, out.dst)
+
+		env := os.Environ()
+		for name, value := range vars {
+			env = append(env, name+"="+value)
+		}
+		c := exec.Command(shell)
+		c.Env = env
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		if err := c.Run(); err != nil {
+			// the exit code of the last command of the shell is not an error of the playground
+			if _, ok := err.(*exec.ExitError); !ok {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// playgroundEnv returns the environment variables with the endpoints of the playground
+func playgroundEnv(out *output) (map[string]string, error) {
+	endpoints, err := loadEndpoints(out)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := filepath.Abs(out.dst)
+	if err != nil {
+		return nil, err
+	}
+
+	// the jwt secret of the engine api of reth, which has its own one with split-jwt
+	jwt := jwtSecretArtifact
+	if out.Exists(filepath.Join("jwt", "reth")) {
+		jwt = filepath.Join("jwt", "reth")
+	}
+
+	vars := map[string]string{
+		"PLAYGROUND_OUTPUT": dst,
+		"JWT_PATH":          filepath.Join(dst, jwt),
+		"PRIVATE_KEY":       prefundedAccounts[0],
+	}
+	for name, endpoint := range endpointVars {
+		if url, ok := endpoints[endpoint[0]][endpoint[1]]; ok {
+			vars[name] = url
+		}
+	}
+	return vars, nil
+}
+
+// shellQuote quotes the value for a posix shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+var skewFlag []string
+
+// serviceSkews are the clock offsets of --skew by service
+var serviceSkews map[string]time.Duration
+
+// faketimeLibs are the default install locations of libfaketime
+var faketimeLibs = map[string][]string{
+	"linux": {
+		"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1",
+		"/usr/lib/aarch64-linux-gnu/faketime/libfaketime.so.1",
+		"/usr/lib/faketime/libfaketime.so.1",
+		"/usr/local/lib/faketime/libfaketime.so.1",
+	},
+	"darwin": {
+		"/opt/homebrew/lib/faketime/libfaketime.1.dylib",
+		"/usr/local/lib/faketime/libfaketime.1.dylib",
+	},
+}
+
+// faketimeLib is the path of libfaketime, resolved when --skew is used
+var faketimeLib string
+
+// parseSkews parses the clock offsets of the form <service>=<offset>, where the offset
+// is a signed duration (i.e. reth=+2s or beacon_node=-500ms)
+func parseSkews(items []string) (map[string]time.Duration, error) {
+	skews := map[string]time.Duration{}
+	for _, item := range items {
+		name, offsetStr, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid skew '%s', expected <service>=<offset>", item)
+		}
+		offset, err := time.ParseDuration(offsetStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset '%s' of %s, expected a duration (i.e. +2s or -500ms)", offsetStr, name)
+		}
+		skews[name] = offset
+	}
+
+	if len(skews) != 0 {
+		lib, err := findFaketimeLib()
+		if err != nil {
+			return nil, err
+		}
+		faketimeLib = lib
+	}
+	return skews, nil
+}
+
+// findFaketimeLib returns the path of libfaketime from PLAYGROUND_FAKETIME_LIB or
+// the default install locations
+func findFaketimeLib() (string, error) {
+	if lib := os.Getenv("PLAYGROUND_FAKETIME_LIB"); lib != "" {
+		return lib, nil
+	}
+	for _, lib := range faketimeLibs[runtime.GOOS] {
+		if _, err := os.Stat(lib); err == nil {
+			return lib, nil
+		}
+	}
+	return "", fmt.Errorf("the clock skew of the services requires libfaketime, install it or set PLAYGROUND_FAKETIME_LIB")
+}
+
+// withSkew runs the service with libfaketime preloaded, which shifts the clock of the
+// process by the offset. It does not apply to statically linked binaries.
+func (s *service) withSkew(offset time.Duration) *service {
+	if runtime.GOOS == "darwin" {
+		s.WithEnv("DYLD_INSERT_LIBRARIES", faketimeLib).WithEnv("DYLD_FORCE_FLAT_NAMESPACE", "1")
+	} else {
+		s.WithEnv("LD_PRELOAD", faketimeLib)
+	}
+	// a relative offset in seconds, the clock keeps running from the start of the process
+	return s.WithEnv("FAKETIME", fmt.Sprintf("%+.3f", offset.Seconds()))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// slotClock is the timing of the beacon chain
+type slotClock struct {
+	genesisTime    uint64
+	secondsPerSlot uint64
+	slotsPerEpoch  uint64
+}
+
+// fetchSlotClock reads the genesis time and the slot settings from the beacon node
+func fetchSlotClock(ctx context.Context) (*slotClock, error) {
+	var genesis struct {
+		Data struct {
+			GenesisTime string `json:"genesis_time"`
+		} `json:"data"`
+	}
+	if err := httpGetJSON(ctx, "http://localhost:3500/eth/v1/beacon/genesis", &genesis); err != nil {
+		return nil, err
+	}
+	var spec struct {
+		Data struct {
+			SecondsPerSlot string `json:"SECONDS_PER_SLOT"`
+			SlotsPerEpoch  string `json:"SLOTS_PER_EPOCH"`
+		} `json:"data"`
+	}
+	if err := httpGetJSON(ctx, "http://localhost:3500/eth/v1/config/spec", &spec); err != nil {
+		return nil, err
+	}
+
+	c := &slotClock{}
+	var err error
+	if c.genesisTime, err = strconv.ParseUint(genesis.Data.GenesisTime, 10, 64); err != nil {
+		return nil, err
+	}
+	if c.secondsPerSlot, err = strconv.ParseUint(spec.Data.SecondsPerSlot, 10, 64); err != nil {
+		return nil, err
+	}
+	if c.slotsPerEpoch, err = strconv.ParseUint(spec.Data.SlotsPerEpoch, 10, 64); err != nil {
+		return nil, err
+	}
+	if c.secondsPerSlot == 0 || c.slotsPerEpoch == 0 {
+		return nil, fmt.Errorf("invalid spec: %d seconds per slot, %d slots per epoch", c.secondsPerSlot, c.slotsPerEpoch)
+	}
+	return c, nil
+}
+
+// Started returns whether the genesis time has passed
+func (c *slotClock) Started(now time.Time) bool {
+	return uint64(now.Unix()) >= c.genesisTime
+}
+
+// Slot returns the current slot, 0 before the genesis
+func (c *slotClock) Slot(now time.Time) uint64 {
+	if !c.Started(now) {
+		return 0
+	}
+	return (uint64(now.Unix()) - c.genesisTime) / c.secondsPerSlot
+}
+
+func (c *slotClock) Epoch(slot uint64) uint64 {
+	return slot / c.slotsPerEpoch
+}
+
+// UntilNextSlot returns the time until the next slot starts (or the genesis)
+func (c *slotClock) UntilNextSlot(now time.Time) time.Duration {
+	next := time.Unix(int64(c.genesisTime), 0)
+	if c.Started(now) {
+		next = next.Add(time.Duration((c.Slot(now)+1)*c.secondsPerSlot) * time.Second)
+	}
+	return next.Sub(now)
+}
+
+// slotHasBlock returns whether there is a block in the canonical chain at the slot
+func slotHasBlock(ctx context.Context, slot uint64) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://localhost:3500/eth/v1/beacon/headers/%d", slot), nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := healthClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+}
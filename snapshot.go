@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotOutFlag string
+var fromSnapshotFlag string
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Copy the chain of the running playground to restore it later",
+	Long:  `Pause the services of the playground running in the output folder, copy the output folder (the data of reth and the beacon node, the genesis and the keys) to --out and resume them. Start the playground with --from-snapshot <dir> to continue the chain of the snapshot.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if snapshotOutFlag == "" {
+			return fmt.Errorf("--out is required")
+		}
+		if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+		if !chainExists(out) {
+			return fmt.Errorf("no chain found in %s", out.dst)
+		}
+
+		// the services are paused so that their data is consistent while it is copied
+		if err := signalAllServices(out, syscall.SIGSTOP, "paused"); err != nil {
+			signalAllServices(out, syscall.SIGCONT, "resumed")
+			return err
+		}
+		snapshotErr := snapshotOutput(out, snapshotOutFlag)
+		if err := signalAllServices(out, syscall.SIGCONT, "resumed"); err != nil {
+			return err
+		}
+		if snapshotErr != nil {
+			return fmt.Errorf("failed to snapshot the output folder: %w", snapshotErr)
+		}
+
+		fmt.Printf("Snapshot written to %s\n", snapshotOutFlag)
+		return appendEvent(out, fmt.Sprintf("snapshot to %s", snapshotOutFlag))
+	},
+}
+
+// chainExists returns whether the folder has the chain of a playground. The layout is
+// not written by older versions and the genesis is not written for a public network.
+func chainExists(out *output) bool {
+	return out.Exists(layoutArtifact) || out.Exists("genesis.json")
+}
+
+// restoreSnapshot replaces the output folder with a copy of the snapshot
+func restoreSnapshot(snapshot string, out *output) error {
+	src := &output{dst: snapshot}
+	if !chainExists(src) {
+		return fmt.Errorf("no chain found in the snapshot %s", snapshot)
+	}
+	absSrc, err := filepath.Abs(snapshot)
+	if err != nil {
+		return err
+	}
+	absDst, err := filepath.Abs(out.dst)
+	if err != nil {
+		return err
+	}
+	if absSrc == absDst {
+		return fmt.Errorf("the snapshot cannot be the output folder, use --continue")
+	}
+
+	fmt.Printf("Restoring the snapshot %s...\n", snapshot)
+	if err := out.Remove(""); err != nil {
+		return err
+	}
+	return snapshotOutput(src, out.dst)
+}
+
+// snapshotOutput copies the files of the output folder, except the ones of the running
+// session, to the folder dst
+func snapshotOutput(out *output, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	skip := map[string]bool{
+		"pids":                true,
+		playgroundPidArtifact: true,
+		sessionArtifact:       true,
+	}
+
+	return filepath.WalkDir(out.dst, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(out.dst, path)
+		if err != nil {
+			return err
+		}
+		if skip[rel] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		// i.e. the ipc sockets
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// playgroundPidArtifact is the pid of the playground process while it runs
+const playgroundPidArtifact = "playground.pid"
+
+// states of a service
+const (
+	serviceRunning = "running"
+	servicePaused  = "paused"
+	serviceWaiting = "waiting"
+	serviceExited  = "exited"
+	serviceStopped = "stopped"
+
+	// states of a job once it is not running
+	jobCompleted = "completed"
+	jobFailed    = "failed"
+)
+
+var statusJSONFlag bool
+
+type serviceStatus struct {
+	Name      string            `json:"name"`
+	State     string            `json:"state"`
+	Pid       int               `json:"pid,omitempty"`
+	InProcess bool              `json:"in_process"`
+	Ports     map[string]string `json:"ports,omitempty"`
+	Uptime    string            `json:"uptime,omitempty"`
+
+	// Usage is only set with --stats
+	Usage *processUsage `json:"usage,omitempty"`
+}
+
+// chainStatus is the slot clock of the chain and whether the last slot had a block
+type chainStatus struct {
+	Slot          uint64 `json:"slot"`
+	Epoch         uint64 `json:"epoch"`
+	SlotInEpoch   uint64 `json:"slot_in_epoch"`
+	SlotsPerEpoch uint64 `json:"slots_per_epoch"`
+	Started       bool   `json:"started"`
+
+	// NextSlotIn is the time until the next slot, or the genesis if it has not started
+	NextSlotIn string `json:"next_slot_in"`
+
+	// LastSlotProposed is whether the previous slot has a block (nil before the first slot)
+	LastSlotProposed *bool `json:"last_slot_proposed,omitempty"`
+}
+
+type playgroundStatus struct {
+	// Chain is only set while the beacon node is reachable
+	Chain    *chainStatus     `json:"chain,omitempty"`
+	Services []*serviceStatus `json:"services"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status [session]",
+	Short: "Show the state of the services of a playground",
+	Long:  `Show the state (running, paused, waiting, exited or stopped, and completed or failed for the jobs), the endpoints and the uptime of each service (and its cpu and memory usage with --stats), and the slot clock of the chain of the playground running in the output folder (or the session with the given id, see ls)`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		} else if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		statuses, err := collectStatus(out)
+		if err != nil {
+			return err
+		}
+		if statusStatsFlag {
+			collectUsage(statuses)
+		}
+		status := &playgroundStatus{Services: statuses}
+		if chain, err := collectChainStatus(cmd.Context()); err == nil {
+			status.Chain = chain
+		}
+
+		if statusJSONFlag {
+			data, err := json.MarshalIndent(status, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if chain := status.Chain; chain != nil {
+			if !chain.Started {
+				fmt.Printf("Genesis in %s\n", chain.NextSlotIn)
+			} else {
+				line := fmt.Sprintf("Slot %d (epoch %d, slot %d/%d), next slot in %s", chain.Slot, chain.Epoch, chain.SlotInEpoch+1, chain.SlotsPerEpoch, chain.NextSlotIn)
+				if chain.LastSlotProposed != nil {
+					if *chain.LastSlotProposed {
+						line += fmt.Sprintf(", slot %d proposed", chain.Slot-1)
+					} else {
+						line += fmt.Sprintf(", slot %d missed", chain.Slot-1)
+					}
+				}
+				fmt.Println(line)
+			}
+			fmt.Println()
+		}
+
+		for _, s := range statuses {
+			line := fmt.Sprintf("- %s: %s", s.Name, s.State)
+			if s.Uptime != "" {
+				line += fmt.Sprintf(" (up %s)", s.Uptime)
+			}
+			if s.Usage != nil {
+				line += fmt.Sprintf(", %s", s.Usage)
+			}
+			fmt.Println(line)
+			ports := []string{}
+			for port := range s.Ports {
+				ports = append(ports, port)
+			}
+			sort.Strings(ports)
+			for _, port := range ports {
+				fmt.Printf("    %s: %s\n", port, s.Ports[port])
+			}
+		}
+		return nil
+	},
+}
+
+// collectStatus returns the state of each service in the topology of the output folder
+func collectStatus(out *output) ([]*serviceStatus, error) {
+	topo, err := loadTopology(out)
+	if err != nil {
+		return nil, err
+	}
+
+	playgroundPid, playgroundStart, playgroundRunning := readPidFile(filepath.Join(out.dst, playgroundPidArtifact))
+
+	statuses := []*serviceStatus{}
+	for _, node := range topo.Nodes {
+		s := &serviceStatus{Name: node.Name, InProcess: node.InProcess, Ports: map[string]string{}}
+		for _, p := range node.Ports {
+			s.Ports[p.Name] = p.URL
+		}
+
+		if node.InProcess {
+			if playgroundRunning {
+				s.State = serviceRunning
+				s.Pid = playgroundPid
+				s.Uptime = formatUptime(playgroundStart)
+			} else {
+				s.State = serviceStopped
+			}
+		} else if pid, start, running := readPidFile(filepath.Join(out.dst, pidFilePath(node.Name))); running {
+			s.State = processState(pid)
+			s.Pid = pid
+			s.Uptime = formatUptime(start)
+		} else if node.Job && out.Exists(jobArtifact(node.Name)) {
+			s.State = jobCompleted
+		} else if !playgroundRunning {
+			s.State = serviceStopped
+		} else if !artifactsExist(out, node.Artifacts) {
+			s.State = serviceWaiting
+		} else if node.Job {
+			s.State = jobFailed
+		} else {
+			s.State = serviceExited
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// collectChainStatus reads the slot clock from the beacon node
+func collectChainStatus(ctx context.Context) (*chainStatus, error) {
+	clock, err := fetchSlotClock(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	slot := clock.Slot(now)
+	chain := &chainStatus{
+		Slot:          slot,
+		Epoch:         clock.Epoch(slot),
+		SlotInEpoch:   slot % clock.slotsPerEpoch,
+		SlotsPerEpoch: clock.slotsPerEpoch,
+		Started:       clock.Started(now),
+		NextSlotIn:    clock.UntilNextSlot(now).Round(time.Second).String(),
+	}
+	if slot > 0 {
+		// the current slot might not have its block yet, check the previous one
+		if proposed, err := slotHasBlock(ctx, slot-1); err == nil {
+			chain.LastSlotProposed = &proposed
+		}
+	}
+	return chain, nil
+}
+
+// readPidFile returns the pid in the file, when it was written and whether the
+// process is still alive
+func readPidFile(path string) (int, time.Time, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return pid, info.ModTime(), syscall.Kill(pid, 0) == nil
+}
+
+// processState returns whether the process is running or stopped with SIGSTOP (paused)
+func processState(pid int) string {
+	var state string
+	if runtime.GOOS == "linux" {
+		// the state is the field after the command, which is between parenthesis
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err == nil {
+			if idx := strings.LastIndex(string(data), ")"); idx != -1 {
+				state = strings.TrimSpace(string(data[idx+1:]))
+			}
+		}
+	} else {
+		data, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+		if err == nil {
+			state = strings.TrimSpace(string(data))
+		}
+	}
+	if strings.HasPrefix(state, "T") {
+		return servicePaused
+	}
+	return serviceRunning
+}
+
+func artifactsExist(out *output, artifacts []string) bool {
+	for _, artifact := range artifacts {
+		if !out.Exists(artifact) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatUptime(start time.Time) string {
+	return time.Since(start).Round(time.Second).String()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// artifactStore is where the output writes and reads the artifacts. The paths are
+// relative to the root of the store. Only the artifacts read and written whole through
+// the output (i.e. the genesis, the keys and the json files) go through the store. The
+// files used by the processes or as streams (the logs, the pid files, events.log, the
+// recordings and the data folders) are always in the local folder, so a remote output
+// only works with the commands that read artifacts, which is the hosts command for now.
+type artifactStore interface {
+	Read(path string) ([]byte, error)
+	Write(path string, data []byte) error
+	Exists(path string) bool
+	Remove(path string) error
+
+	// Sub returns the store rooted at the folder of this store
+	Sub(dir string) artifactStore
+}
+
+// newOutput returns the output of the folder, or of the remote artifacts if dst is an
+// http(s):// or s3:// url (i.e. the output folder uploaded with --upload-artifacts).
+// The remote outputs are read-only.
+func newOutput(dst string) *output {
+	if isRemoteOutput(dst) {
+		return &output{dst: dst, store: newRemoteStore(dst)}
+	}
+	return &output{dst: dst}
+}
+
+func isRemoteOutput(dst string) bool {
+	return strings.HasPrefix(dst, "http://") || strings.HasPrefix(dst, "https://") || strings.HasPrefix(dst, "s3://")
+}
+
+// localStore stores the artifacts in a folder of the local filesystem
+type localStore struct {
+	root string
+}
+
+func (l *localStore) Read(path string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(l.root, path))
+}
+
+func (l *localStore) Write(path string, data []byte) error {
+	dst := filepath.Join(l.root, path)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
+
+func (l *localStore) Exists(path string) bool {
+	_, err := os.Stat(filepath.Join(l.root, path))
+	return err == nil
+}
+
+func (l *localStore) Remove(path string) error {
+	return os.RemoveAll(filepath.Join(l.root, path))
+}
+
+func (l *localStore) Sub(dir string) artifactStore {
+	return &localStore{root: filepath.Join(l.root, dir)}
+}
+
+// remoteStore reads the artifacts over http when they are first used and caches them.
+// The s3:// urls are read from the public https endpoint of the bucket.
+type remoteStore struct {
+	baseURL string
+	client  *http.Client
+
+	lock  *sync.Mutex
+	cache map[string][]byte
+}
+
+func newRemoteStore(url string) *remoteStore {
+	if bucketPath, ok := strings.CutPrefix(url, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(bucketPath, "/")
+		url = fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucket, prefix)
+	}
+	return &remoteStore{
+		baseURL: strings.TrimSuffix(url, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		lock:    &sync.Mutex{},
+		cache:   map[string][]byte{},
+	}
+}
+
+func (r *remoteStore) url(p string) string {
+	return r.baseURL + "/" + strings.TrimPrefix(filepath.ToSlash(p), "/")
+}
+
+func (r *remoteStore) Read(p string) ([]byte, error) {
+	url := r.url(p)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if data, ok := r.cache[url]; ok {
+		return data, nil
+	}
+
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
+		// s3 returns forbidden for the missing objects of the buckets that cannot be listed
+		return nil, &fs.PathError{Op: "get", Path: url, Err: fs.ErrNotExist}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.cache[url] = data
+	return data, nil
+}
+
+func (r *remoteStore) Write(p string, data []byte) error {
+	return fmt.Errorf("cannot write %s, the remote output is read-only", p)
+}
+
+func (r *remoteStore) Exists(p string) bool {
+	_, err := r.Read(p)
+	return err == nil
+}
+
+func (r *remoteStore) Remove(p string) error {
+	return fmt.Errorf("cannot remove %s, the remote output is read-only", p)
+}
+
+func (r *remoteStore) Sub(dir string) artifactStore {
+	return &remoteStore{baseURL: r.url(dir), client: r.client, lock: r.lock, cache: r.cache}
+}
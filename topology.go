@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// types of the dependencies between the services
+const (
+	dependencyEngineAPI  = "engine-api"
+	dependencyBeaconAPI  = "beacon-api"
+	dependencyBuilderAPI = "builder-api"
+	dependencyRPC        = "rpc"
+	dependencyP2P        = "p2p"
+)
+
+// dependency is a connection from a service to a port of another service
+type dependency struct {
+	service string
+	port    string
+	kind    string
+}
+
+type topologyPort struct {
+	Name     string `json:"name"`
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	URL      string `json:"url"`
+}
+
+type topologyNode struct {
+	Name      string          `json:"name"`
+	Ports     []*topologyPort `json:"ports"`
+	Artifacts []string        `json:"artifacts,omitempty"`
+	InProcess bool            `json:"in_process,omitempty"`
+	Job       bool            `json:"job,omitempty"`
+
+	// Command and Env are the command line and the environment variables set by the
+	// playground, after the templates, the overrides and the limits are applied
+	Command []string `json:"command,omitempty"`
+	Env     []string `json:"env,omitempty"`
+
+	// HostEnv are the variables of the playground passed to the service with
+	// --env-passthrough. Without it, the service inherits all of them.
+	HostEnv []string `json:"host_env,omitempty"`
+}
+
+type topologyEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Port string `json:"port"`
+	Type string `json:"type"`
+}
+
+// topology is the graph of the services and the connections between them
+type topology struct {
+	Nodes []*topologyNode `json:"nodes"`
+	Edges []*topologyEdge `json:"edges"`
+}
+
+func newTopology(services []*service) *topology {
+	t := &topology{}
+	for _, svc := range services {
+		node := &topologyNode{Name: svc.name, Artifacts: svc.artifactDeps, InProcess: svc.inProcess, Job: svc.job}
+		if !svc.inProcess {
+			node.Command = svc.command()
+			node.Env = svc.env
+			node.HostEnv = passthroughEnvNames(svc.name, envPassthroughFlag)
+		}
+		for _, p := range svc.ports {
+			node.Ports = append(node.Ports, &topologyPort{Name: p.name, Port: p.port, Protocol: p.protocol, URL: p.URL()})
+		}
+		t.Nodes = append(t.Nodes, node)
+
+		for _, dep := range svc.deps {
+			t.Edges = append(t.Edges, &topologyEdge{From: svc.name, To: dep.service, Port: dep.port, Type: dep.kind})
+		}
+	}
+	sort.Slice(t.Nodes, func(i, j int) bool {
+		return t.Nodes[i].Name < t.Nodes[j].Name
+	})
+	sort.Slice(t.Edges, func(i, j int) bool {
+		if t.Edges[i].From != t.Edges[j].From {
+			return t.Edges[i].From < t.Edges[j].From
+		}
+		return t.Edges[i].To < t.Edges[j].To
+	})
+	return t
+}
+
+// loadTopology reads the topology written in the output folder by the playground
+func loadTopology(out *output) (*topology, error) {
+	data, err := out.ReadFile("topology.json")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("topology.json not found in %s, the playground has not run", out.dst)
+		}
+		return nil, err
+	}
+	var topo topology
+	if err := json.Unmarshal(data, &topo); err != nil {
+		return nil, err
+	}
+	return &topo, nil
+}
+
+// node returns the service of the topology with the name, if any
+func (t *topology) node(name string) *topologyNode {
+	for _, node := range t.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// Artifacts returns the topology in json, DOT and Mermaid formats
+func (t *topology) Artifacts() map[string]interface{} {
+	return map[string]interface{}{
+		"topology.json": t,
+		"topology.dot":  t.Dot(),
+		"topology.mmd":  t.Mermaid(),
+	}
+}
+
+func (t *topology) Dot() string {
+	var b strings.Builder
+	b.WriteString("digraph playground {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=record];\n")
+	for _, node := range t.Nodes {
+		ports := []string{}
+		for _, p := range node.Ports {
+			ports = append(ports, fmt.Sprintf("<%s> %s: %d", dotID(p.Name), p.Name, p.Port))
+		}
+		label := node.Name
+		if len(ports) != 0 {
+			label = fmt.Sprintf("{%s|%s}", node.Name, strings.Join(ports, "|"))
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\"];\n", dotID(node.Name), label)
+	}
+	for _, edge := range t.Edges {
+		fmt.Fprintf(&b, "  %s -> %s:%s [label=\"%s\"];\n", dotID(edge.From), dotID(edge.To), dotID(edge.Port), edge.Type)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (t *topology) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for _, node := range t.Nodes {
+		ports := []string{}
+		for _, p := range node.Ports {
+			ports = append(ports, fmt.Sprintf("%s: %d", p.Name, p.Port))
+		}
+		label := node.Name
+		if len(ports) != 0 {
+			label += "<br/>" + strings.Join(ports, "<br/>")
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", dotID(node.Name), label)
+	}
+	for _, edge := range t.Edges {
+		fmt.Fprintf(&b, "  %s -->|%s %s| %s\n", dotID(edge.From), edge.Type, edge.Port, dotID(edge.To))
+	}
+	return b.String()
+}
+
+// dotID returns a valid identifier for DOT and Mermaid
+func dotID(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// uploadArtifacts copies the output folder (without the chain data folders) to a remote
+// bucket under a prefix unique for the session. It supports s3:// and gs:// destinations
+// and relies on the 'aws' or 'gsutil' clis being installed and configured.
+func uploadArtifacts(dst string, sessionID string, retention string, out *output) error {
+	u, err := url.Parse(dst)
+	if err != nil {
+		return fmt.Errorf("invalid upload destination '%s': %w", dst, err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid upload destination '%s': bucket not found", dst)
+	}
+	remote := fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, strings.Trim(strings.TrimPrefix(u.Path, "/")+"/"+sessionID, "/"))
+
+	var cmd *exec.Cmd
+	switch u.Scheme {
+	case "s3":
+		args := []string{"s3", "cp", "--recursive", "--exclude", "data_*", out.dst, remote}
+		if retention != "" {
+			args = append(args, "--metadata", "retention="+retention)
+		}
+		cmd = exec.Command("aws", args...)
+	case "gs":
+		args := []string{"-m"}
+		if retention != "" {
+			args = append(args, "-h", "x-goog-meta-retention:"+retention)
+		}
+		args = append(args, "cp", "-r", "-x", "^data_.*", out.dst+"/*", remote)
+		cmd = exec.Command("gsutil", args...)
+	default:
+		return fmt.Errorf("unsupported upload destination scheme '%s', only s3:// and gs:// are supported", u.Scheme)
+	}
+
+	logger.Info("Uploading artifacts", "remote", remote)
+
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to upload artifacts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var statusStatsFlag bool
+
+// usageSampleInterval is the time between the two samples of the cpu time of the
+// processes of the services
+const usageSampleInterval = 500 * time.Millisecond
+
+// clockTicks is the unit of the cpu times in /proc/<pid>/stat (USER_HZ), which is
+// 100 on every Linux architecture
+const clockTicks = 100
+
+// processUsage is the cpu and memory usage of the process of a service
+type processUsage struct {
+	// CPUPercent is the cpu usage, where 100 is one core fully used
+	CPUPercent float64 `json:"cpu_percent"`
+
+	// MemoryRSS is the resident memory in bytes
+	MemoryRSS uint64 `json:"memory_rss"`
+}
+
+func (p *processUsage) String() string {
+	return fmt.Sprintf("cpu %.1f%%, mem %d MB", p.CPUPercent, p.MemoryRSS>>20)
+}
+
+// collectUsage sets the cpu and memory usage of the services with a process. The
+// services that run inside the playground process report the usage of the playground.
+func collectUsage(statuses []*serviceStatus) {
+	pids := []int{}
+	for _, s := range statuses {
+		if s.Pid != 0 {
+			pids = append(pids, s.Pid)
+		}
+	}
+
+	usages := map[int]*processUsage{}
+	if runtime.GOOS == "linux" {
+		usages = sampleProcUsage(pids)
+	} else {
+		for _, pid := range pids {
+			if usage, err := psUsage(pid); err == nil {
+				usages[pid] = usage
+			}
+		}
+	}
+	for _, s := range statuses {
+		if usage, ok := usages[s.Pid]; ok {
+			s.Usage = usage
+		}
+	}
+}
+
+// sampleProcUsage reads the cpu time of the processes twice to compute the cpu usage
+// between the two samples and reads their resident memory
+func sampleProcUsage(pids []int) map[int]*processUsage {
+	before := map[int]uint64{}
+	for _, pid := range pids {
+		if ticks, err := procCPUTicks(pid); err == nil {
+			before[pid] = ticks
+		}
+	}
+	start := time.Now()
+	time.Sleep(usageSampleInterval)
+	elapsed := time.Since(start).Seconds()
+
+	usages := map[int]*processUsage{}
+	for pid, ticksBefore := range before {
+		ticks, err := procCPUTicks(pid)
+		if err != nil || ticks < ticksBefore {
+			continue
+		}
+		rss, err := procRSS(pid)
+		if err != nil {
+			continue
+		}
+		usages[pid] = &processUsage{
+			CPUPercent: float64(ticks-ticksBefore) / clockTicks / elapsed * 100,
+			MemoryRSS:  rss,
+		}
+	}
+	return usages
+}
+
+// procCPUTicks returns the user and system cpu time of the process in clock ticks
+func procCPUTicks(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// the command is between parenthesis and can have spaces, the fields after it
+	// start with the state (field 3), utime and stime are the fields 14 and 15
+	idx := strings.LastIndex(string(data), ")")
+	if idx == -1 {
+		return 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("invalid /proc/%d/stat", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return utime + stime, nil
+}
+
+// procRSS returns the resident memory of the process in bytes
+func procRSS(pid int) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	// size resident shared ... in pages
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid /proc/%d/statm", pid)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// psUsage returns the usage of the process with ps, where the cpu usage is the one
+// reported by the os (a decaying average on macOS)
+func psUsage(pid int) (*processUsage, error) {
+	data, err := exec.Command("ps", "-o", "%cpu=,rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("unexpected output of ps '%s'", strings.TrimSpace(string(data)))
+	}
+	cpu, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return nil, err
+	}
+	rssKB, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &processUsage{CPUPercent: cpu, MemoryRSS: rssKB << 10}, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/prysmaticlabs/prysm/v5/runtime/interop"
+)
+
+// defaultNumValidators is the number of validators of the local devnet without --num-validators
+const defaultNumValidators = 100
+
+var numValidatorsFlag uint64
+
+// validatorKeystoresArtifact has the keystores of all the validators. With --validator-split
+// each validator client gets a copy of its share in its own folder.
+const validatorKeystoresArtifact = "data_validator"
+
+var validatorSplitFlag []string
+var vcCountFlag uint64
+
+// validatorClientRelease is the release binary of the validator client of each consensus client
+var validatorClientRelease = map[string]string{
+	clLighthouse: "lighthouse",
+	clPrysm:      "validator",
+}
+
+// validatorClient is a validator client service with a share of the validator keys
+type validatorClient struct {
+	name   string
+	client string
+
+	// keys is the folder with the keystores of the share (validators and secrets)
+	keys string
+
+	// first and last (exclusive) are the indexes of the validators of the share
+	first int
+	last  int
+}
+
+// prysmDir is the folder with the wallet and the database of the prysm validator
+func (v *validatorClient) prysmDir() string {
+	if len(validatorSplit()) == 0 {
+		return "data_validator_prysm"
+	}
+	return filepath.Join(v.keys, "prysm")
+}
+
+// importJob is the job that imports the keystores into the wallet of the prysm validator
+func (v *validatorClient) importJob() string {
+	return prysmImportJob + v.name[len("validator"):]
+}
+
+// validatorSplit returns the types of the validator clients the keys are split across,
+// empty for a single validator client. --vc-count is a shorthand of --validator-split
+// with clients of --cl-client.
+func validatorSplit() []string {
+	if vcCountFlag > 1 {
+		split := []string{}
+		for i := uint64(0); i < vcCountFlag; i++ {
+			split = append(split, clClientFlag)
+		}
+		return split
+	}
+	return validatorSplitFlag
+}
+
+// validatorClients returns the validator clients of the local devnet. Without --validator-split
+// there is a single validator of --cl-client with all the keys. Otherwise, the keys are split
+// in contiguous ranges across the clients of the flag, named validator, validator-2...
+func validatorClients() []*validatorClient {
+	split := validatorSplit()
+	if len(split) == 0 {
+		return []*validatorClient{
+			{name: "validator", client: clClientFlag, keys: validatorKeystoresArtifact, first: 0, last: int(numValidatorsFlag)},
+		}
+	}
+
+	vcs := []*validatorClient{}
+	for i, client := range split {
+		name := "validator"
+		if i != 0 {
+			name += "-" + strconv.Itoa(i+1)
+		}
+		vcs = append(vcs, &validatorClient{
+			name:   name,
+			client: client,
+			keys:   filepath.Join("data_validators", name),
+			first:  i * int(numValidatorsFlag) / len(split),
+			last:   (i + 1) * int(numValidatorsFlag) / len(split),
+		})
+	}
+	return vcs
+}
+
+func validateValidatorSplit() error {
+	if vcCountFlag > 1 && len(validatorSplitFlag) != 0 {
+		return fmt.Errorf("--vc-count cannot be used with --validator-split")
+	}
+	if len(validatorSplit()) == 0 {
+		return nil
+	}
+	if networkFlag != "" {
+		return fmt.Errorf("--validator-split cannot be used with --network, there are no local validators")
+	}
+	if uint64(len(validatorSplit())) > numValidatorsFlag {
+		return fmt.Errorf("--validator-split cannot have more than %d validator clients", numValidatorsFlag)
+	}
+	for _, client := range validatorSplit() {
+		if _, ok := validatorClientRelease[client]; !ok {
+			return fmt.Errorf("unknown validator client '%s' in --validator-split, it must be %s or %s", client, clLighthouse, clPrysm)
+		}
+	}
+	return nil
+}
+
+// writeValidatorSplit copies the keystores of each validator client with --validator-split
+// from the keystores of all the validators
+func writeValidatorSplit(out *output) error {
+	if len(validatorSplit()) == 0 {
+		return nil
+	}
+
+	// the keystores are named by public key, the keys are generated again to know
+	// the public key of each validator index
+	priv, _, err := interop.DeterministicallyGenerateKeys(0, numValidatorsFlag)
+	if err != nil {
+		return err
+	}
+
+	for _, vc := range validatorClients() {
+		files := map[string]interface{}{}
+		for _, key := range priv[vc.first:vc.last] {
+			pubKeyHex := fmt.Sprintf("0x%x", key.PublicKey().Marshal())
+			for _, path := range []string{
+				filepath.Join("validators", pubKeyHex, "voting-keystore.json"),
+				filepath.Join("secrets", pubKeyHex),
+			} {
+				data, err := out.ReadFile(filepath.Join(validatorKeystoresArtifact, path))
+				if err != nil {
+					return fmt.Errorf("failed to read the keystore of validator %s: %w", pubKeyHex, err)
+				}
+				files[filepath.Join(vc.keys, path)] = data
+			}
+		}
+		if err := out.WriteBatch(files); err != nil {
+			return err
+		}
+		logger.Info("Validator client keys", "validator", vc.name, "client", vc.client, "first", vc.first, "last", vc.last-1)
+	}
+	return nil
+}
+
+// runValidators starts the validator clients of the local devnet
+func runValidators(svcManager *serviceManager, bins map[string]string) error {
+	// there are no local validators in a public network
+	if networkFlag != "" {
+		return nil
+	}
+	for i, vc := range validatorClients() {
+		bin := bins[validatorClientRelease[vc.client]]
+		if vc.client == clPrysm {
+			if err := runPrysmValidator(svcManager, vc, bin, i); err != nil {
+				return err
+			}
+		} else {
+			runLighthouseValidator(svcManager, vc, bin)
+		}
+	}
+	return nil
+}
+
+func runLighthouseValidator(svcManager *serviceManager, vc *validatorClient, lighthouseBin string) {
+	svcManager.
+		NewService(vc.name).
+		WithArgs(
+			lighthouseBin,
+			"vc",
+			"--datadir", "{{.Dir}}/"+vc.keys,
+			"--testnet-dir", "{{.Dir}}/testnet",
+			"--init-slashing-protection",
+			"--beacon-nodes", "http://localhost:3500",
+			"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990",
+			"--builder-proposals",
+		).
+		WithDependency("beacon_node", "http", dependencyBeaconAPI).
+		Run()
+}
+
+// runPrysmValidator starts a prysm validator. The index of the validator client sets its
+// monitoring port, so that several prysm validators can run side by side.
+func runPrysmValidator(svcManager *serviceManager, vc *validatorClient, validatorBin string, index int) error {
+	version := prysmVersion(validatorBin)
+	if err := checkComponentVersion("prysm", version); err != nil {
+		return err
+	}
+
+	// the prysm validator reads the keystores from its wallet, import the ones of its share
+	svcManager.
+		NewService(vc.importJob()).
+		WithArgs(
+			validatorBin,
+			"accounts", "import",
+			"--accept-terms-of-use",
+			"--keys-dir", "{{.Dir}}/"+vc.keys+"/validators",
+			"--wallet-dir", "{{.Dir}}/"+vc.prysmDir()+"/wallet",
+			"--wallet-password-file", "{{.Dir}}/"+vc.prysmDir()+"/wallet_password",
+			"--account-password-file", "{{.Dir}}/"+vc.prysmDir()+"/account_password",
+		).
+		AsJob(time.Minute).
+		Run()
+
+	svcManager.
+		NewService(vc.name).
+		WithArgs(
+			validatorBin,
+			"--accept-terms-of-use",
+			"--datadir", "{{.Dir}}/"+vc.prysmDir(),
+			"--chain-config-file", "{{.Dir}}/testnet/config.yaml",
+			"--wallet-dir", "{{.Dir}}/"+vc.prysmDir()+"/wallet",
+			"--wallet-password-file", "{{.Dir}}/"+vc.prysmDir()+"/wallet_password",
+			"--monitoring-port", strconv.Itoa(8081+index),
+			"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990",
+			"--enable-builder",
+		).
+		If(
+			clClientFlag == clPrysm,
+			func(s *service) *service {
+				return s.
+					WithArgs("--beacon-rpc-provider", "localhost:4000").
+					WithDependency("beacon_node", "rpc", dependencyBeaconAPI)
+			},
+		).
+		If(
+			// the prysm validator only uses the beacon api of the other beacon nodes
+			clClientFlag != clPrysm,
+			func(s *service) *service {
+				return s.
+					WithArgs("--enable-beacon-rest-api", "--beacon-rest-api-provider", "http://localhost:3500").
+					WithDependency("beacon_node", "http", dependencyBeaconAPI)
+			},
+		).
+		DependsOnArtifact(jobArtifact(vc.importJob())).
+		Run()
+
+	return nil
+}
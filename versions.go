@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/semver"
+)
+
+// versionArgs are arguments of a component that only apply to the versions in
+// the range [since, until). An empty bound means that the range is open.
+type versionArgs struct {
+	since string
+	until string
+	args  []string
+}
+
+func (v *versionArgs) Match(version string) bool {
+	if v.since != "" && semver.Compare(version, v.since) < 0 {
+		return false
+	}
+	if v.until != "" && semver.Compare(version, v.until) >= 0 {
+		return false
+	}
+	return true
+}
+
+// componentCapabilities describes the versions supported by a component and the
+// arguments that change across them.
+type componentCapabilities struct {
+	minVersion string
+	args       []*versionArgs
+}
+
+var capabilities = map[string]*componentCapabilities{
+	"reth": {
+		minVersion: "v1.0.0",
+		args: []*versionArgs{
+			// For versions >= v1.1.0, we need to run with --engine.legacy, at least for now
+			{since: "v1.1.0", args: []string{"--engine.legacy"}},
+		},
+	},
+	"lighthouse": {
+		minVersion: "v5.0.0",
+		args: []*versionArgs{
+			// For versions <= v5.2.1, we want to run with --http-allow-sync-stalled
+			// However this flag is not available in newer versions
+			{until: "v5.3.0", args: []string{"--http-allow-sync-stalled"}},
+			// For versions >= v5.3.0, ----suggested-fee-recipient is apparently now required for non-validator nodes as well
+			{since: "v5.3.0", args: []string{"--suggested-fee-recipient", "0x690B9A9E9aa1C9dB991C7721a92d351Db4FaC990"}},
+		},
+	},
+	"prysm": {
+		// the beacon api port is set with --http-port
+		minVersion: "v5.1.1",
+	},
+}
+
+// checkComponentVersion fails if the version of the component is older than the
+// minimum version supported. An unknown version is allowed with a warning.
+func checkComponentVersion(component, version string) error {
+	c, ok := capabilities[component]
+	if !ok {
+		return fmt.Errorf("BUG: component %s not found in the capabilities table", component)
+	}
+	if !semver.IsValid(version) {
+		logger.Warn("Could not detect the version, the arguments might not be compatible", "component", component)
+		return nil
+	}
+	if semver.Compare(version, c.minVersion) < 0 {
+		return fmt.Errorf("%s %s is not supported, the minimum version is %s", component, version, c.minVersion)
+	}
+	return nil
+}
+
+// WithVersionArgs adds the arguments of the component that apply to its version
+func (s *service) WithVersionArgs(component, version string) *service {
+	c, ok := capabilities[component]
+	if !ok {
+		panic(fmt.Sprintf("BUG: component %s not found in the capabilities table", component))
+	}
+	for _, v := range c.args {
+		if v.Match(version) {
+			s = s.WithArgs(v.args...)
+		}
+	}
+	return s
+}
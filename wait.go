@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var waitForFlag []string
+var waitTimeoutFlag time.Duration
+var waitIntervalFlag time.Duration
+
+// serviceHealthy is the state of a service that is running and passes its health check
+const serviceHealthy = "healthy"
+
+var waitStates = []string{serviceRunning, servicePaused, serviceWaiting, serviceExited, serviceStopped, jobCompleted, jobFailed, serviceHealthy}
+
+// waitCondition is a condition of the wait command, either on the state of a service or
+// on the chain (with a freezeCondition)
+type waitCondition struct {
+	raw string
+
+	service string
+	state   string
+
+	chain *freezeCondition
+}
+
+// parseWaitCondition parses the condition of the form service:<name>:<state>,
+// block>=<number> or slot>=<number>
+func parseWaitCondition(s string) (*waitCondition, error) {
+	if rest, ok := strings.CutPrefix(s, "service:"); ok {
+		name, state, ok := strings.Cut(rest, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid condition '%s', expected service:<name>:<state>", s)
+		}
+		if !slices.Contains(waitStates, state) {
+			return nil, fmt.Errorf("unknown state '%s' in '%s', it must be one of %s", state, s, strings.Join(waitStates, ", "))
+		}
+		return &waitCondition{raw: s, service: name, state: state}, nil
+	}
+
+	kind, valueStr, ok := strings.Cut(s, ">=")
+	if !ok || (kind != freezeAtBlock && kind != freezeAtSlot) {
+		return nil, fmt.Errorf("invalid condition '%s', expected service:<name>:<state>, block>=<number> or slot>=<number>", s)
+	}
+	value, err := strconv.ParseUint(valueStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s '%s'", kind, valueStr)
+	}
+	return &waitCondition{raw: s, chain: &freezeCondition{kind: kind, value: value}}, nil
+}
+
+var waitCmd = &cobra.Command{
+	Use:   "wait [session]",
+	Short: "Wait until the conditions on the playground hold",
+	Long:  `Wait until all the --for conditions hold on the playground running in the output folder (or the session with the given id, see ls). The conditions are service:<name>:<state>, where the state is one of the status command or healthy, block>=<number> and slot>=<number>. It fails if the conditions do not hold before the --timeout.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(waitForFlag) == 0 {
+			return fmt.Errorf("at least one --for condition is required")
+		}
+		conds := []*waitCondition{}
+		for _, item := range waitForFlag {
+			cond, err := parseWaitCondition(item)
+			if err != nil {
+				return err
+			}
+			conds = append(conds, cond)
+		}
+
+		if len(args) == 1 {
+			if err := resolveSessionOutput(args[0]); err != nil {
+				return err
+			}
+		} else if err := resolveOutputFlag(); err != nil {
+			return err
+		}
+		out := &output{dst: outputFlag}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), waitTimeoutFlag)
+		defer cancel()
+
+		for {
+			pending, err := pendingWaitConditions(ctx, out, conds)
+			if err != nil {
+				return err
+			}
+			if len(pending) == 0 {
+				fmt.Println("All the conditions hold")
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				if cmd.Context().Err() != nil {
+					return cmd.Context().Err()
+				}
+				return fmt.Errorf("timeout after %s waiting for %s", waitTimeoutFlag, strings.Join(pending, ", "))
+			case <-time.After(waitIntervalFlag):
+			}
+		}
+	},
+}
+
+// resolveSessionOutput sets the output folder to the one of the running session
+func resolveSessionOutput(id string) error {
+	sessions, err := listSessions()
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.ID == id {
+			outputFlag = s.Output
+			return nil
+		}
+	}
+	return fmt.Errorf("session '%s' not found, run 'ls' to list the running sessions", id)
+}
+
+// pendingWaitConditions returns the conditions that do not hold yet
+func pendingWaitConditions(ctx context.Context, out *output, conds []*waitCondition) ([]string, error) {
+	// the topology is written once the playground starts the services
+	started := out.Exists("topology.json")
+	states := map[string]string{}
+	if started {
+		statuses, err := collectStatus(out)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range statuses {
+			states[s.Name] = s.State
+		}
+	}
+
+	// the health checks only run if a condition needs them
+	var health map[string]error
+	healthOf := func(name string) error {
+		if health == nil {
+			health = map[string]error{}
+			for _, svc := range collectHealthSummary(ctx, nil).services {
+				health[svc.name] = svc.err
+			}
+		}
+		return health[name]
+	}
+
+	pending := []string{}
+	for _, cond := range conds {
+		if cond.chain != nil {
+			// the chain is not reachable until the services start
+			if reached, err := cond.chain.reached(ctx); err != nil || !reached {
+				pending = append(pending, cond.raw)
+			}
+			continue
+		}
+
+		if !started {
+			pending = append(pending, cond.raw)
+			continue
+		}
+		state, ok := states[cond.service]
+		if !ok {
+			return nil, fmt.Errorf("service '%s' of '%s' not found in the playground", cond.service, cond.raw)
+		}
+		if cond.state != serviceHealthy {
+			if state != cond.state {
+				pending = append(pending, cond.raw)
+			}
+			continue
+		}
+		// a running service without a health check is healthy
+		if state != serviceRunning {
+			pending = append(pending, cond.raw)
+		} else if healthOf(cond.service) != nil {
+			pending = append(pending, cond.raw)
+		}
+	}
+	return pending, nil
+}